@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 
 	dlp "github.com/laojianzi/godlp"
@@ -260,6 +261,76 @@ func BenchmarkEngine_DeIdentifyJSON1m(b *testing.B) {
 	}
 }
 
+func BenchmarkEngine_DeIdentifyVDict1k(b *testing.B) {
+	benchmarkDeIdentifyVDict(b, 1000)
+}
+
+func BenchmarkEngine_DeIdentifyVDict100k(b *testing.B) {
+	benchmarkDeIdentifyVDict(b, 100000)
+}
+
+func BenchmarkEngine_DeIdentifyCDict10k(b *testing.B) {
+	src, err := Read("./testdata/test_1k.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	text := dupString(src, 10)
+	// rule 1 alone has a large CDict
+	eng, err := dlp.NewEngine(CallerSys)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err = eng.ApplyConfig(strings.Replace(eng.GetDefaultConf(), "EnableRules: []", "EnableRules: [1]", 1)); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err = eng.DeIdentify(text); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkDeIdentifyVDict runs DeIdentify on 10k text with a rule which has dictSize words in VDict
+func benchmarkDeIdentifyVDict(b *testing.B, dictSize int) {
+	src, err := Read("./testdata/test_1k.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	text := dupString(src, 10)
+	var confBuf bytes.Buffer
+	confBuf.WriteString("Global:\n  ApiVersion: v2\n  Mode: release\n" +
+		"MaskRules:\n  - RuleName: ALL\n    MaskType: CHAR\n    Value: \"*\"\n" +
+		"Rules:\n  - RuleID: 10001\n    InfoType: DICT\n    Mask: ALL\n    Detect:\n      VDict: [")
+	for i := 0; i < dictSize; i++ {
+		if i > 0 {
+			confBuf.WriteString(", ")
+		}
+		confBuf.WriteString("word" + strconv.Itoa(i))
+	}
+	confBuf.WriteString("]\n")
+
+	eng, err := dlp.NewEngine(CallerSys)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if err = eng.ApplyConfig(confBuf.String()); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err = eng.DeIdentify(text); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 // 判断文件是否存在  存在返回 true 不存在返回false
 //
 // nolint: unused
@@ -2,7 +2,6 @@
 package conf
 
 import (
-	"fmt"
 	"os"
 	"strings"
 
@@ -31,12 +30,25 @@ type RuleItem struct {
 	EnName      string `yaml:"EnName"`
 	CnName      string `yaml:"CnName"`
 	Level       string `yaml:"Level"` // L1 (least Sensitive) ~ L4 (Most Sensitive)
+	// Require is optional, the value is detected only if it contains one of Require, such as ["@"] for EMAIL.
+	// Literals and digits required by VReg are derived automatically, Require is for the other cases.
+	Require []string `yaml:"Require,flow,omitempty"`
+	// MultiLine is optional, the rule is evaluated on the whole input instead of line by line, so results can
+	// span lines, such as PEM blocks. Stream APIs evaluate it on a sliding window of lines. Only for VALUE rules.
+	MultiLine bool `yaml:"MultiLine,omitempty"`
+	// Overlap is optional, it overrides Global.Overlap when a result of the rule overlaps another one
+	Overlap string `yaml:"Overlap,omitempty"`
+	// Priority is optional, a result with higher Priority wins in PRIORITY policy of Overlap
+	Priority int32 `yaml:"Priority,omitempty"`
 	// (KReg || KDict) && (VReg || VDict)
 	Detect struct {
 		KReg  []string `yaml:"KReg"`       // Regex List for Key
 		KDict []string `yaml:"KDict,flow"` // Dict for Key
 		VReg  []string `yaml:"VReg"`       // Regex List for Value
 		VDict []string `yaml:"VDict,flow"` // Dict for Value
+		// Path is optional, keys of DetectMap and paths of DetectJSON are detected only if they match one of Path,
+		// glob such as "/user/**", "/users[*]/phone", "trace_id" or JSONPath such as "$..phone"
+		Path []string `yaml:"Path,omitempty"`
 	} `yaml:"Detect"`
 	// result which is hit by blacklist will not return to caller
 	Filter struct {
@@ -50,10 +62,33 @@ type RuleItem struct {
 		// CReg || CDict
 		CReg  []string `yaml:"CReg"`       // Regex List for Context Verification
 		CDict []string `yaml:"CDict,flow"` // Dict for Context Verification
-		VAlgo []string `yaml:"VAlgo"`      // Algorithm List for Verification, one of [ IDVerify , CardVerify ]
+		// Algorithm List for Verification, one of [ IDCARD, ABAROUTING, CREDITCARD, BITCOIN, DOMAIN, ENTROPY, JWT ]
+		VAlgo []string `yaml:"VAlgo"`
+		// Entropy is the min Shannon entropy in bits per character of ENTROPY, 3.0 if 0
+		Entropy float64 `yaml:"Entropy,omitempty"`
+		// NReg || NDict, result is dropped if one of them is hit around it
+		NReg  []string `yaml:"NReg,omitempty"`       // Regex List for Negative Context
+		NDict []string `yaml:"NDict,flow,omitempty"` // Dict for Negative Context
+		// Context is optional, it is the window around a result where CDict, CReg, NDict and NReg are checked,
+		// the window never crosses a line boundary
+		Context struct {
+			Range     int32  `yaml:"Range"`     // size of the window on each side, 32 if 0
+			Unit      string `yaml:"Unit"`      // unit of Range, one of [ RUNE, WORD ], RUNE if empty
+			Direction string `yaml:"Direction"` // one of [ BOTH, BEFORE, AFTER ], BOTH if empty
+		} `yaml:"Context,omitempty"`
 	} `yaml:"Verify"`
-	Mask    string            `yaml:"Mask"` // MaskRuleItem.RuleName for Mask
-	ExtInfo map[string]string `yaml:"ExtInfo"`
+	// Score is optional, score of a result is Base plus boosts of passed checks, at most 1, 0 means the default
+	Score struct {
+		Base    float64 `yaml:"Base"`    // score of a detected result
+		Context float64 `yaml:"Context"` // boost if CDict or CReg is hit around the result
+		Algo    float64 `yaml:"Algo"`    // boost if the result passes VAlgo
+		Key     float64 `yaml:"Key"`     // boost if the key is hit by KDict or KReg
+		// Soft keeps results which fail Verify without the boost, instead of dropping them
+		Soft bool `yaml:"Soft"`
+	} `yaml:"Score,omitempty"`
+	MinScore float64           `yaml:"MinScore,omitempty"` // results with lower score are dropped, 0 means no limit
+	Mask     string            `yaml:"Mask"`               // MaskRuleItem.RuleName for Mask
+	ExtInfo  map[string]string `yaml:"ExtInfo"`
 }
 
 type DlpConf struct {
@@ -64,86 +99,117 @@ type DlpConf struct {
 		AllowRPC       bool    `yaml:"AllowRPC"`
 		EnableRules    []int32 `yaml:"EnableRules,flow"`
 		DisableRules   []int32 `yaml:"DisableRules,flow"`
+		MaxInput       int32   `yaml:"MaxInput,omitempty"`
+		MaxItem        int32   `yaml:"MaxItem,omitempty"`
+		MaxCallDeep    int32   `yaml:"MaxCallDeep,omitempty"`
 		MaxLogInput    int32   `yaml:"MaxLogInput"`
+		MaxLogItem     int32   `yaml:"MaxLogItem,omitempty"`
 		MaxRegexRuleID int32   `yaml:"MaxRegexRuleID"`
+		ParallelInput  int32   `yaml:"ParallelInput,omitempty"`
+		MaxWorkers     int32   `yaml:"MaxWorkers,omitempty"`
+		// items of DetectMap, DetectJSON and DeIdentify* are skipped if their paths do not match IncludePaths
+		// or match ExcludePaths, patterns are the same as Detect.Path of Rules
+		IncludePaths []string `yaml:"IncludePaths,omitempty"`
+		ExcludePaths []string `yaml:"ExcludePaths,omitempty"`
+		// Deobfuscate detects text again after spaced and spelled-out digits and "at/dot" emails are canonicalized
+		Deobfuscate bool `yaml:"Deobfuscate,omitempty"`
+		// Overlap is the policy which decides the results kept when results overlap, CONTAIN if empty
+		Overlap string `yaml:"Overlap,omitempty"`
 	} `yaml:"Global"`
 	MaskRules []MaskRuleItem `yaml:"MaskRules"`
 	Rules     []RuleItem     `yaml:"Rules"`
+	source    string         // YAML source, used for line numbers of VerifyErrors
+	// verifyAlgos are names accepted in Verify.VAlgo besides the built-in ones, set by WithVerifyAlgos
+	verifyAlgos []string
+	// maskNames are names accepted in Mask of Rules besides RuleName of MaskRules, set by WithMaskNames
+	maskNames []string
+}
+
+// Option sets optional parameters of DlpConf which are used by Verify
+type Option func(c *DlpConf)
+
+// WithVerifyAlgos makes Verify accept names in Verify.VAlgo besides the built-in ones,
+// such as names of verifiers registered by Engine.RegisterVerifier
+func WithVerifyAlgos(names ...string) Option {
+	return func(c *DlpConf) {
+		c.verifyAlgos = append(c.verifyAlgos, names...)
+	}
+}
+
+// WithMaskNames makes Verify accept names in Mask of Rules besides RuleName of MaskRules,
+// such as names of maskers registered by Engine.RegisterMasker
+func WithMaskNames(names ...string) Option {
+	return func(c *DlpConf) {
+		c.maskNames = append(c.maskNames, names...)
+	}
 }
 
 // NewDlpConf creates DlpConf object by conf content string
 // public func
-func NewDlpConf(confString string) (*DlpConf, error) {
-	return newDlpConfImpl(confString)
+func NewDlpConf(confString string, opts ...Option) (*DlpConf, error) {
+	return newDlpConfImpl(confString, opts)
 }
 
 // NewDlpConfByPath creates DlpConf object by confPath
-func NewDlpConfByPath(confPath string) (*DlpConf, error) {
+func NewDlpConfByPath(confPath string, opts ...Option) (*DlpConf, error) {
 	if len(confPath) == 0 {
 		return nil, header.ErrConfPathEmpty
 	}
 	if fileData, err := os.ReadFile(confPath); err == nil {
-		return newDlpConfImpl(string(fileData))
+		return newDlpConfImpl(string(fileData), opts)
 	} else {
 		return nil, err
 	}
 }
 
+// LimitOff set as Global.MaxRegexRuleID or Global.ParallelInput turns them off explicitly, 0 means they are
+// taken from Engine, so LimitOff is the way to use no regex rule in log processor or never detect in parallel
+const LimitOff = -1
+
+// policies of Global.Overlap and Overlap of rules, a tie of LEVEL, SCORE and PRIORITY is broken by the longer
+// span, then by the higher RuleID
+const (
+	// OverlapContain drops a result contained by another one of the same Key, but keeps the shorter one of two
+	// results with the same start, on equal spans the higher RuleID wins, it is the default
+	OverlapContain = "CONTAIN"
+	// OverlapLevel keeps the result with the highest Level of overlapping results
+	OverlapLevel = "LEVEL"
+	// OverlapScore keeps the result with the highest Score of overlapping results
+	OverlapScore = "SCORE"
+	// OverlapLongest keeps the result with the longest span of overlapping results
+	OverlapLongest = "LONGEST"
+	// OverlapPriority keeps the result with the highest Priority of rules of overlapping results
+	OverlapPriority = "PRIORITY"
+	// OverlapAll keeps all overlapping results
+	OverlapAll = "ALL"
+)
+
 var (
 	defModeSet          = []string{"debug", "release"}
 	defAPIVersionPrefix = "v2"
 	defMaskTypeSet      = []string{"CHAR", "TAG", "REPLACE", "ALGO"}
 	defMaskAlgo         = []string{"BASE64", "MD5", "CRC32", "ADDRESS", "NUMBER", "DEIDENTIFY"}
 	defIgnoreKind       = []string{"NUMERIC", "ALPHA_UPPER_CASE", "ALPHA_LOWER_CASE", "WHITESPACE", "PUNCTUATION"}
+	defVerifyAlgo       = []string{"IDCARD", "ABAROUTING", "CREDITCARD", "BITCOIN", "DOMAIN", "ENTROPY", "JWT"}
+	defBlacklistAlgo    = []string{"MASKED"}
+	defContextUnit      = []string{"RUNE", "WORD"}
+	defContextDirection = []string{"BOTH", "BEFORE", "AFTER"}
+	defOverlapPolicy    = []string{
+		OverlapContain, OverlapLevel, OverlapScore, OverlapLongest, OverlapPriority, OverlapAll,
+	}
 )
 
+// Verify checks the whole config and returns all problems at once as VerifyErrors, nil means no problem.
+// Regexes are compiled, duplicated RuleID and MaskRules.RuleName, unknown VAlgo, BAlgo and Mask references,
+// EnableRules and DisableRules which are not in Rules are all reported with RuleID and YAML line number.
 func (I *DlpConf) Verify() error {
-	// Global
-
-	// ApiVersion
-	if !strings.HasPrefix(I.Global.ApiVersion, defAPIVersionPrefix) {
-		return fmt.Errorf("%w, Global.APIVersion:%s failed", header.ErrConfVerifyFailed, I.Global.ApiVersion)
-	}
-	// Mode
-	I.Global.Mode = strings.ToLower(I.Global.Mode)
-	if inList(I.Global.Mode, defModeSet) == -1 { // not found
-		return fmt.Errorf("%w, Global.Mode:%s failed", header.ErrConfVerifyFailed, I.Global.Mode)
-	}
-	// MaskRules
-	for _, rule := range I.MaskRules {
-		// MaskType
-		if inList(rule.MaskType, defMaskTypeSet) == -1 {
-			return fmt.Errorf("%w, Mask RuleName:%s, MaskType:%s is not suppored",
-				header.ErrConfVerifyFailed, rule.RuleName, rule.MaskType)
-		}
-		if strings.Compare(rule.MaskType, "ALGO") == 0 {
-			if inList(rule.Value, defMaskAlgo) == -1 {
-				return fmt.Errorf("%w, Mask RuleName:%s, ALGO Value: %s is not supported",
-					header.ErrConfVerifyFailed, rule.RuleName, rule.Value)
-			}
-		}
-		if !(rule.Offset >= 0) {
-			return fmt.Errorf("%w, Mask RuleName:%s, Offset: %d need >=0",
-				header.ErrConfVerifyFailed, rule.RuleName, rule.Offset)
-		}
-		if !(rule.Length >= 0) {
-			return fmt.Errorf("%w, Mask RuleName:%s, Length: %d need >=0",
-				header.ErrConfVerifyFailed, rule.RuleName, rule.Length)
-		}
-		for _, kind := range rule.IgnoreKind {
-			if inList(kind, defIgnoreKind) == -1 {
-				return fmt.Errorf("%w, Mask RuleName:%s, IgnoreKind: %s is not supported",
-					header.ErrConfVerifyFailed, rule.RuleName, kind)
-			}
-		}
-	}
-	// Rules
-	for _, rule := range I.Rules {
-		de := rule.Detect
-		// at least one detect rule
-		if len(de.KReg) == 0 && len(de.KDict) == 0 && len(de.VReg) == 0 && len(de.VDict) == 0 {
-			return fmt.Errorf("%w, RuleID:%d, Detect field missing", header.ErrConfVerifyFailed, rule.RuleID)
-		}
+	v := &confVerifier{conf: I, idx: newLineIndex(I.source)}
+	v.verifyGlobal()
+	v.verifyMaskRules()
+	v.verifyRules()
+	v.verifyRuleRefs()
+	if len(v.errs) != 0 {
+		return v.errs
 	}
 	return nil
 }
@@ -151,11 +217,14 @@ func (I *DlpConf) Verify() error {
 // private func
 
 // newDlpConfImpl implements newDlpConf by receiving conf content string
-func newDlpConfImpl(confString string) (*DlpConf, error) {
+func newDlpConfImpl(confString string, opts []Option) (*DlpConf, error) {
 	if len(confString) == 0 {
 		return nil, header.ErrConfEmpty
 	}
-	confObj := new(DlpConf)
+	confObj := &DlpConf{source: confString}
+	for _, opt := range opts {
+		opt(confObj)
+	}
 	if err := yaml.Unmarshal([]byte(confString), &confObj); err == nil {
 		if err := confObj.Verify(); err == nil {
 			return confObj, nil
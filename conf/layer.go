@@ -0,0 +1,210 @@
+// Package conf layer.go implements layered configuration, default rules overlaid by private layers
+package conf
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/laojianzi/godlp/header"
+)
+
+// DefPrivateRuleIDStart is the first RuleID of private rules, 0 < default RuleID < DefPrivateRuleIDStart
+const DefPrivateRuleIDStart int32 = 10000
+
+// DefSecretRuleIDStart ~ DefSecretRuleIDEnd is the RuleID range reserved for default rules of secrets and
+// credentials, such as access keys, tokens and passwords
+const (
+	DefSecretRuleIDStart int32 = 1000
+	DefSecretRuleIDEnd   int32 = 1099
+)
+
+// defAppendGlobalKeys are Global list items which are appended by layers instead of overwritten
+var defAppendGlobalKeys = []string{"EnableRules", "DisableRules", "IncludePaths", "ExcludePaths"}
+
+// NewDlpConfLayers creates DlpConf object by merging layers in order, layers[0] is the base config with
+// default rules, the other layers are private overlays, they may be partial documents:
+//   - Global items overwrite the base one, except EnableRules, DisableRules, IncludePaths and ExcludePaths
+//     which are appended
+//   - MaskRules with the same RuleName overwrite the given fields of the base one, others are added
+//   - Rules with the same RuleID overwrite the given fields of the base one, others are added
+//   - the same RuleName or RuleID listed twice in one layer is reported as a VerifyError
+//
+// default rules of the base must have RuleID < DefPrivateRuleIDStart, new rules of overlays must have
+// RuleID >= DefPrivateRuleIDStart. The merged config is verified as a whole, VerifyErrors of it have no
+// line numbers because the merged YAML is not written by the user, RuleID and RuleName locate the problem.
+func NewDlpConfLayers(layers ...string) (*DlpConf, error) {
+	return NewDlpConfLayersWithOptions(layers)
+}
+
+// NewDlpConfLayersWithOptions is NewDlpConfLayers with options, such as WithVerifyAlgos
+func NewDlpConfLayersWithOptions(layers []string, opts ...Option) (*DlpConf, error) {
+	if len(layers) == 0 || len(layers[0]) == 0 {
+		return nil, header.ErrConfEmpty
+	}
+	merged := make(map[interface{}]interface{})
+	for i, layer := range layers {
+		doc := make(map[interface{}]interface{})
+		if err := yaml.Unmarshal([]byte(layer), &doc); err != nil {
+			return nil, fmt.Errorf("layer[%d]: %w", i, err)
+		}
+		if err := mergeLayer(merged, doc, i); err != nil {
+			return nil, err
+		}
+	}
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	// line numbers of the merged YAML do not match any layer, drop them
+	opts = append(opts[:len(opts):len(opts)], func(c *DlpConf) { c.source = "" })
+	return newDlpConfImpl(string(out), opts)
+}
+
+// private func
+
+// mergeLayer merges the layer doc into merged, idx is the index of the layer, 0 is the base
+func mergeLayer(merged map[interface{}]interface{}, doc map[interface{}]interface{}, idx int) error {
+	var errs VerifyErrors
+	for key, value := range doc {
+		switch key {
+		case "Global":
+			mergeGlobal(merged, value)
+		case "MaskRules":
+			list, dups := mergeList(merged[key], value, "RuleName", idx, nil)
+			merged[key] = list
+			errs = append(errs, dups...)
+		case "Rules":
+			list, dups, err := mergeRuleList(merged[key], value, idx)
+			if err != nil {
+				return err
+			}
+			merged[key] = list
+			errs = append(errs, dups...)
+		default:
+			merged[key] = value
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// mergeGlobal merges Global section of a layer into merged
+func mergeGlobal(merged map[interface{}]interface{}, value interface{}) {
+	src, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return
+	}
+	dst, ok := merged["Global"].(map[interface{}]interface{})
+	if !ok {
+		dst = make(map[interface{}]interface{})
+		merged["Global"] = dst
+	}
+	for k, v := range src {
+		name, _ := k.(string)
+		oldList, isOldList := dst[k].([]interface{})
+		newList, isNewList := v.([]interface{})
+		if isOldList && isNewList && inList(name, defAppendGlobalKeys) != -1 {
+			dst[k] = append(oldList, newList...)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// mergeRuleList merges Rules section of a layer into the merged one, RuleID ranges are checked
+func mergeRuleList(merged interface{}, value interface{}, idx int) (interface{}, VerifyErrors, error) {
+	var retErr error
+	out, dups := mergeList(merged, value, "RuleID", idx, func(item map[interface{}]interface{}, isNew bool) {
+		if retErr != nil || !isNew {
+			return
+		}
+		ruleID, ok := item["RuleID"].(int)
+		if !ok {
+			retErr = fmt.Errorf("%w, layer[%d] RuleID:%v is not an integer",
+				header.ErrConfVerifyFailed, idx, item["RuleID"])
+			return
+		}
+		isDefault := int32(ruleID) < DefPrivateRuleIDStart
+		if idx == 0 && !isDefault {
+			retErr = fmt.Errorf("%w, RuleID:%d of default rules must be less than %d",
+				header.ErrConfVerifyFailed, ruleID, DefPrivateRuleIDStart)
+		} else if idx > 0 && isDefault {
+			retErr = fmt.Errorf("%w, layer[%d] RuleID:%d is reserved for default rules and not found, "+
+				"private rules must be greater than or equal to %d",
+				header.ErrConfVerifyFailed, idx, ruleID, DefPrivateRuleIDStart)
+		}
+	})
+	return out, dups, retErr
+}
+
+// mergeList merges items of value into merged by key field, fields of an item with the same key overwrite
+// the old one, new items are appended. check is called for every item of value if it is not nil.
+// Items of value with the same key are a mistake of the layer idx, they are reported instead of merged.
+func mergeList(merged interface{}, value interface{}, key string, idx int,
+	check func(item map[interface{}]interface{}, isNew bool),
+) (interface{}, VerifyErrors) {
+	dst, _ := merged.([]interface{})
+	src, ok := value.([]interface{})
+	if !ok {
+		return dst, nil
+	}
+	index := make(map[string]map[interface{}]interface{}, len(dst))
+	for _, v := range dst {
+		if item, ok := v.(map[interface{}]interface{}); ok {
+			index[fmt.Sprint(item[key])] = item
+		}
+	}
+	var dups VerifyErrors
+	seen := make(map[string]bool, len(src))
+	for _, v := range src {
+		item, ok := v.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		id := fmt.Sprint(item[key])
+		if seen[id] {
+			dups = append(dups, newDupError(key, item[key], idx))
+			continue
+		}
+		seen[id] = true
+		old, found := index[id]
+		if check != nil {
+			check(item, !found)
+		}
+		if found {
+			mergeMap(old, item)
+			continue
+		}
+		index[id] = item
+		dst = append(dst, item)
+	}
+	return dst, dups
+}
+
+// newDupError creates the VerifyError of an item which is duplicated in the layer idx
+func newDupError(key string, id interface{}, idx int) *VerifyError {
+	e := &VerifyError{Field: key, Msg: fmt.Sprintf("is duplicated in layer[%d]", idx), Err: header.ErrConfVerifyFailed}
+	if key == "RuleID" {
+		ruleID, _ := id.(int)
+		e.RuleID = int32(ruleID)
+	} else {
+		e.RuleName = fmt.Sprint(id)
+	}
+	return e
+}
+
+// mergeMap overwrites dst by src, nested maps are merged recursively
+func mergeMap(dst map[interface{}]interface{}, src map[interface{}]interface{}) {
+	for k, v := range src {
+		subSrc, isSrcMap := v.(map[interface{}]interface{})
+		subDst, isDstMap := dst[k].(map[interface{}]interface{})
+		if isSrcMap && isDstMap {
+			mergeMap(subDst, subSrc)
+			continue
+		}
+		dst[k] = v
+	}
+}
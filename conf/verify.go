@@ -0,0 +1,384 @@
+// Package conf verify.go implements the structured error list returned by DlpConf.Verify
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/laojianzi/godlp/header"
+	"github.com/laojianzi/godlp/internal/keypath"
+)
+
+// VerifyError is one problem of the config found by Verify
+type VerifyError struct {
+	RuleID   int32  // RuleID of Rules, 0 if the problem is not in Rules
+	RuleName string // RuleName of MaskRules, empty if the problem is not in MaskRules
+	Field    string // field path, such as Global.Mode, Detect.VReg
+	Line     int    // 1-based line number in YAML, 0 if unknown
+	Msg      string
+	Err      error // header.ErrConfVerifyFailed or header.ErrRegexCompileFailed
+}
+
+// Error implements error
+func (e *VerifyError) Error() string {
+	var sb strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, "line %d: ", e.Line)
+	}
+	if e.RuleID != 0 {
+		fmt.Fprintf(&sb, "RuleID:%d, ", e.RuleID)
+	}
+	if len(e.RuleName) != 0 {
+		fmt.Fprintf(&sb, "Mask RuleName:%s, ", e.RuleName)
+	}
+	fmt.Fprintf(&sb, "%s: %s", e.Field, e.Msg)
+	return sb.String()
+}
+
+// Unwrap returns the sentinel error of e
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyErrors is the list of all problems found by Verify,
+// errors.Is(err, header.ErrConfVerifyFailed) is true for it
+type VerifyErrors []*VerifyError
+
+// Error implements error, one problem per line
+func (errs VerifyErrors) Error() string {
+	lines := make([]string, 0, len(errs)+1)
+	lines = append(lines, fmt.Sprintf("%s, %d problem(s) found", header.ErrConfVerifyFailed.Error(), len(errs)))
+	for _, e := range errs {
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Is reports whether target is header.ErrConfVerifyFailed or the sentinel error of any problem
+func (errs VerifyErrors) Is(target error) bool {
+	if target == header.ErrConfVerifyFailed {
+		return true
+	}
+	for _, e := range errs {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// private func
+
+var (
+	reYamlRuleID   = regexp.MustCompile(`^\s*(?:-\s+)?RuleID:\s*(-?\d+)`)
+	reYamlRuleName = regexp.MustCompile(`^\s*(?:-\s+)?RuleName:\s*["']?([^"'#\s]+)`)
+)
+
+// lineIndex finds line numbers of rules in YAML source, yaml.v2 does not keep positions in decoded structs
+type lineIndex struct {
+	lines        []string
+	ruleLine     map[int32][]int  // RuleID -> lines of `RuleID:`, duplicated RuleID has more lines
+	maskRuleLine map[string][]int // RuleName -> lines of `RuleName:`
+}
+
+// newLineIndex scans YAML source for RuleID and RuleName lines
+func newLineIndex(source string) *lineIndex {
+	idx := &lineIndex{
+		lines:        strings.Split(source, "\n"),
+		ruleLine:     make(map[int32][]int),
+		maskRuleLine: make(map[string][]int),
+	}
+	for i, line := range idx.lines {
+		if m := reYamlRuleID.FindStringSubmatch(line); m != nil {
+			var ruleID int32
+			if _, err := fmt.Sscan(m[1], &ruleID); err == nil {
+				idx.ruleLine[ruleID] = append(idx.ruleLine[ruleID], i+1)
+			}
+		} else if m := reYamlRuleName.FindStringSubmatch(line); m != nil {
+			idx.maskRuleLine[m[1]] = append(idx.maskRuleLine[m[1]], i+1)
+		}
+	}
+	return idx
+}
+
+// ruleStart returns the line of the nth RuleID:ruleID, 0 if not found
+func (idx *lineIndex) ruleStart(ruleID int32, nth int) int {
+	if list := idx.ruleLine[ruleID]; nth < len(list) {
+		return list[nth]
+	}
+	return 0
+}
+
+// maskRuleStart returns the line of the nth RuleName:name, 0 if not found
+func (idx *lineIndex) maskRuleStart(name string, nth int) int {
+	if list := idx.maskRuleLine[name]; nth < len(list) {
+		return list[nth]
+	}
+	return 0
+}
+
+// find returns the first line at or after start which contains all of needles before the next list item
+// at the same indent as start, start is returned if not found
+func (idx *lineIndex) find(start int, needles ...string) int {
+	if start <= 0 || start > len(idx.lines) {
+		return start
+	}
+	indent := len(idx.lines[start-1]) - len(strings.TrimLeft(idx.lines[start-1], " "))
+	for i := start - 1; i < len(idx.lines); i++ {
+		line := idx.lines[i]
+		if i > start-1 {
+			trimmed := strings.TrimLeft(line, " ")
+			if len(line)-len(trimmed) <= indent && len(trimmed) != 0 && !strings.HasPrefix(trimmed, "#") {
+				break // next item or section
+			}
+		}
+		if containsAll(line, needles) {
+			return i + 1
+		}
+	}
+	return start
+}
+
+// findGlobal returns the line of a Global key, 0 if not found
+func (idx *lineIndex) findGlobal(key string) int {
+	for i, line := range idx.lines {
+		if strings.HasPrefix(strings.TrimSpace(line), key+":") {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// containsAll checks whether s contains all of needles
+func containsAll(s string, needles []string) bool {
+	for _, needle := range needles {
+		if !strings.Contains(s, needle) {
+			return false
+		}
+	}
+	return true
+}
+
+// confVerifier collects problems of a DlpConf
+type confVerifier struct {
+	conf *DlpConf
+	idx  *lineIndex
+	errs VerifyErrors
+	// ruleNth counts occurrences of RuleID, used to find the line of duplicated rules
+	ruleNth map[int32]int
+}
+
+// add appends a problem
+func (v *confVerifier) add(e *VerifyError) {
+	if e.Err == nil {
+		e.Err = header.ErrConfVerifyFailed
+	}
+	v.errs = append(v.errs, e)
+}
+
+// verifyGlobal checks Global section
+func (v *confVerifier) verifyGlobal() {
+	g := &v.conf.Global
+	if !strings.HasPrefix(g.ApiVersion, defAPIVersionPrefix) {
+		v.add(&VerifyError{Field: "Global.ApiVersion", Line: v.idx.findGlobal("ApiVersion"),
+			Msg: fmt.Sprintf("%q need prefix %s", g.ApiVersion, defAPIVersionPrefix)})
+	}
+	g.Mode = strings.ToLower(g.Mode)
+	if inList(g.Mode, defModeSet) == -1 { // not found
+		v.add(&VerifyError{Field: "Global.Mode", Line: v.idx.findGlobal("Mode"),
+			Msg: fmt.Sprintf("%q is not one of %v", g.Mode, defModeSet)})
+	}
+	limits := []struct {
+		name  string
+		value int32
+		off   bool // LimitOff is accepted
+	}{
+		{"MaxInput", g.MaxInput, false}, {"MaxItem", g.MaxItem, false}, {"MaxCallDeep", g.MaxCallDeep, false},
+		{"MaxLogInput", g.MaxLogInput, false}, {"MaxLogItem", g.MaxLogItem, false},
+		{"MaxRegexRuleID", g.MaxRegexRuleID, true}, {"ParallelInput", g.ParallelInput, true},
+		{"MaxWorkers", g.MaxWorkers, false},
+	}
+	for _, limit := range limits {
+		switch {
+		case limit.off && limit.value < LimitOff:
+			v.add(&VerifyError{Field: "Global." + limit.name, Line: v.idx.findGlobal(limit.name),
+				Msg: fmt.Sprintf("%d need >=%d, 0 means the value of Engine, %d means off", limit.value, LimitOff,
+					LimitOff)})
+		case !limit.off && limit.value < 0:
+			v.add(&VerifyError{Field: "Global." + limit.name, Line: v.idx.findGlobal(limit.name),
+				Msg: fmt.Sprintf("%d need >=0, 0 means the value of Engine", limit.value)})
+		}
+	}
+	paths := []struct {
+		name string
+		list []string
+	}{
+		{"IncludePaths", g.IncludePaths}, {"ExcludePaths", g.ExcludePaths},
+	}
+	for _, f := range paths {
+		for _, pattern := range f.list {
+			if _, err := keypath.Compile(pattern); err != nil {
+				v.add(&VerifyError{Field: "Global." + f.name, Line: v.idx.findGlobal(f.name), Msg: err.Error()})
+			}
+		}
+	}
+	if len(g.Overlap) != 0 && inList(g.Overlap, defOverlapPolicy) == -1 {
+		v.add(&VerifyError{Field: "Global.Overlap", Line: v.idx.findGlobal("Overlap"),
+			Msg: fmt.Sprintf("%q is not one of %v", g.Overlap, defOverlapPolicy)})
+	}
+}
+
+// verifyMaskRules checks MaskRules section
+func (v *confVerifier) verifyMaskRules() {
+	nth := make(map[string]int, len(v.conf.MaskRules))
+	for _, rule := range v.conf.MaskRules {
+		line := v.idx.maskRuleStart(rule.RuleName, nth[rule.RuleName])
+		nth[rule.RuleName]++
+		add := func(field, needle, msg string) {
+			v.add(&VerifyError{RuleName: rule.RuleName, Field: field, Line: v.idx.find(line, needle), Msg: msg})
+		}
+		if len(rule.RuleName) == 0 {
+			add("RuleName", "RuleName", "is empty")
+		} else if nth[rule.RuleName] == 2 {
+			add("RuleName", "RuleName", fmt.Sprintf("%q is duplicated, first defined at line %d",
+				rule.RuleName, v.idx.maskRuleStart(rule.RuleName, 0)))
+		}
+		if inList(rule.MaskType, defMaskTypeSet) == -1 {
+			add("MaskType", "MaskType", fmt.Sprintf("%q is not one of %v", rule.MaskType, defMaskTypeSet))
+		}
+		if strings.Compare(rule.MaskType, "ALGO") == 0 && inList(rule.Value, defMaskAlgo) == -1 {
+			add("Value", "Value", fmt.Sprintf("ALGO %q is not one of %v", rule.Value, defMaskAlgo))
+		}
+		if !(rule.Offset >= 0) {
+			add("Offset", "Offset", fmt.Sprintf("%d need >=0", rule.Offset))
+		}
+		if !(rule.Length >= 0) {
+			add("Length", "Length", fmt.Sprintf("%d need >=0", rule.Length))
+		}
+		for _, kind := range rule.IgnoreKind {
+			if inList(kind, defIgnoreKind) == -1 {
+				add("IgnoreKind", kind, fmt.Sprintf("%q is not one of %v", kind, defIgnoreKind))
+			}
+		}
+	}
+}
+
+// verifyRules checks Rules section
+func (v *confVerifier) verifyRules() {
+	maskNames := make([]string, 0, len(v.conf.MaskRules)+len(v.conf.maskNames))
+	for _, rule := range v.conf.MaskRules {
+		maskNames = append(maskNames, rule.RuleName)
+	}
+	maskNames = append(maskNames, v.conf.maskNames...)
+	verifyAlgos := make([]string, 0, len(defVerifyAlgo)+len(v.conf.verifyAlgos))
+	verifyAlgos = append(append(verifyAlgos, defVerifyAlgo...), v.conf.verifyAlgos...)
+	v.ruleNth = make(map[int32]int, len(v.conf.Rules))
+	for i := range v.conf.Rules {
+		rule := &v.conf.Rules[i]
+		line := v.idx.ruleStart(rule.RuleID, v.ruleNth[rule.RuleID])
+		v.ruleNth[rule.RuleID]++
+		add := func(field, needle, msg string, err error) {
+			v.add(&VerifyError{RuleID: rule.RuleID, Field: field, Line: v.idx.find(line, needle), Msg: msg, Err: err})
+		}
+		if rule.RuleID <= 0 {
+			add("RuleID", "RuleID", fmt.Sprintf("%d need >0", rule.RuleID), nil)
+		} else if v.ruleNth[rule.RuleID] == 2 {
+			add("RuleID", "RuleID", fmt.Sprintf("is duplicated, first defined at line %d",
+				v.idx.ruleStart(rule.RuleID, 0)), nil)
+		}
+
+		de := rule.Detect
+		// at least one detect rule
+		if len(de.KReg) == 0 && len(de.KDict) == 0 && len(de.VReg) == 0 && len(de.VDict) == 0 {
+			add("Detect", "Detect", "Detect field missing", nil)
+		}
+		if rule.MultiLine && (len(de.KReg) != 0 || len(de.KDict) != 0) {
+			add("MultiLine", "MultiLine:", "only VALUE rules without KReg and KDict support MultiLine", nil)
+		}
+		regFields := []struct {
+			name string
+			list []string
+		}{
+			{"Detect.KReg", de.KReg}, {"Detect.VReg", de.VReg},
+			{"Filter.BReg", rule.Filter.BReg}, {"Verify.CReg", rule.Verify.CReg}, {"Verify.NReg", rule.Verify.NReg},
+		}
+		for _, f := range regFields {
+			key := f.name[strings.IndexByte(f.name, '.')+1:] + ":"
+			for _, reStr := range f.list {
+				if _, err := regexp.Compile(reStr); err != nil {
+					add(f.name, key, fmt.Sprintf("regex %q compile failed, %s", reStr, err.Error()),
+						header.ErrRegexCompileFailed)
+				}
+			}
+		}
+		for _, pattern := range de.Path {
+			if _, err := keypath.Compile(pattern); err != nil {
+				add("Detect.Path", "Path:", err.Error(), nil)
+			}
+		}
+		for _, algo := range rule.Filter.BAlgo {
+			if inList(algo, defBlacklistAlgo) == -1 {
+				add("Filter.BAlgo", algo, fmt.Sprintf("%q is not one of %v", algo, defBlacklistAlgo), nil)
+			}
+		}
+		for _, algo := range rule.Verify.VAlgo {
+			if inList(algo, verifyAlgos) == -1 {
+				add("Verify.VAlgo", algo, fmt.Sprintf("%q is not one of %v", algo, verifyAlgos), nil)
+			}
+		}
+		scoreFields := []struct {
+			name  string
+			value float64
+		}{
+			{"Score.Base", rule.Score.Base}, {"Score.Context", rule.Score.Context},
+			{"Score.Algo", rule.Score.Algo}, {"Score.Key", rule.Score.Key}, {"MinScore", rule.MinScore},
+		}
+		for _, f := range scoreFields {
+			if f.value < 0 || f.value > 1 {
+				key := f.name[strings.IndexByte(f.name, '.')+1:] + ":"
+				add(f.name, key, fmt.Sprintf("%v need in [0, 1]", f.value), nil)
+			}
+		}
+		if rule.Verify.Entropy < 0 {
+			add("Verify.Entropy", "Entropy:", fmt.Sprintf("%v need >=0", rule.Verify.Entropy), nil)
+		}
+		ctxWin := rule.Verify.Context
+		if ctxWin.Range < 0 {
+			add("Verify.Context.Range", "Range:", fmt.Sprintf("%d need >=0", ctxWin.Range), nil)
+		}
+		if len(ctxWin.Unit) != 0 && inList(ctxWin.Unit, defContextUnit) == -1 {
+			add("Verify.Context.Unit", "Unit:", fmt.Sprintf("%q is not one of %v", ctxWin.Unit, defContextUnit), nil)
+		}
+		if len(ctxWin.Direction) != 0 && inList(ctxWin.Direction, defContextDirection) == -1 {
+			add("Verify.Context.Direction", "Direction:",
+				fmt.Sprintf("%q is not one of %v", ctxWin.Direction, defContextDirection), nil)
+		}
+		if len(rule.Overlap) != 0 && inList(rule.Overlap, defOverlapPolicy) == -1 {
+			add("Overlap", "Overlap:", fmt.Sprintf("%q is not one of %v", rule.Overlap, defOverlapPolicy), nil)
+		}
+		// empty Mask means the result is returned without masking
+		if len(rule.Mask) != 0 && inList(rule.Mask, maskNames) == -1 {
+			add("Mask", "Mask:", fmt.Sprintf("%q is not found in MaskRules or registered maskers", rule.Mask), nil)
+		}
+	}
+}
+
+// verifyRuleRefs checks RuleIDs in Global.EnableRules and Global.DisableRules exist in Rules
+func (v *confVerifier) verifyRuleRefs() {
+	refs := []struct {
+		name string
+		list []int32
+	}{
+		{"EnableRules", v.conf.Global.EnableRules}, {"DisableRules", v.conf.Global.DisableRules},
+	}
+	for _, ref := range refs {
+		for _, ruleID := range ref.list {
+			if _, ok := v.ruleNth[ruleID]; !ok {
+				v.add(&VerifyError{Field: "Global." + ref.name, Line: v.idx.findGlobal(ref.name),
+					Msg: fmt.Sprintf("RuleID:%d is not found in Rules", ruleID)})
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package detector ahocorasick.go implements Aho-Corasick automaton for dictionary matching
+package detector
+
+// acNone marks an empty link or a node without output
+const acNone int32 = -1
+
+// acMatcher is an Aho-Corasick automaton over bytes, matching cost is linear in input size
+// regardless of the number of words. It is immutable after newACMatcher and safe for concurrent use.
+type acMatcher struct {
+	root  [256]int32 // transitions of root, dense for the highest fan-out
+	nodes []acNode
+	// edges of node i are edgeBytes[nodes[i].edgeStart:nodes[i].edgeEnd], sorted by byte
+	edgeBytes []byte
+	edgeTo    []int32
+	outs      [][]int32 // word indexes ending at a node, duplicated words share a node
+	lens      []int     // length of words
+}
+
+// acNode is a state of acMatcher
+type acNode struct {
+	fail      int32 // longest proper suffix which is a prefix of some word
+	dict      int32 // nearest node by fail links which has output, acNone if no such node
+	out       int32 // index of outs, acNone if no word ends here
+	edgeStart int32
+	edgeEnd   int32
+}
+
+// acBuildNode is a trie node used while building acMatcher
+type acBuildNode struct {
+	next map[byte]int32
+	out  int32
+}
+
+// newACMatcher builds acMatcher for words, the index of a word in words is its id, empty words are ignored
+func newACMatcher(words [][]byte) *acMatcher {
+	m := &acMatcher{lens: make([]int, len(words))}
+	trie := []acBuildNode{{out: acNone}}
+	for id, word := range words {
+		m.lens[id] = len(word)
+		if len(word) == 0 {
+			continue
+		}
+		cur := int32(0)
+		for _, b := range word {
+			next, ok := trie[cur].next[b]
+			if !ok {
+				if trie[cur].next == nil {
+					trie[cur].next = make(map[byte]int32)
+				}
+				next = int32(len(trie))
+				trie[cur].next[b] = next
+				trie = append(trie, acBuildNode{out: acNone})
+			}
+			cur = next
+		}
+		if trie[cur].out == acNone {
+			trie[cur].out = int32(len(m.outs))
+			m.outs = append(m.outs, nil)
+		}
+		m.outs[trie[cur].out] = append(m.outs[trie[cur].out], int32(id))
+	}
+
+	// flatten edges, sorted by byte
+	m.nodes = make([]acNode, len(trie))
+	for i := range trie {
+		node := &m.nodes[i]
+		node.out = trie[i].out
+		node.edgeStart = int32(len(m.edgeBytes))
+		for b := 0; b < 256 && len(trie[i].next) > 0; b++ {
+			if to, ok := trie[i].next[byte(b)]; ok {
+				m.edgeBytes = append(m.edgeBytes, byte(b))
+				m.edgeTo = append(m.edgeTo, to)
+			}
+		}
+		node.edgeEnd = int32(len(m.edgeBytes))
+		trie[i].next = nil // release memory as early as possible
+	}
+	for b := range m.root {
+		m.root[b] = m.child(0, byte(b))
+		if m.root[b] == acNone {
+			m.root[b] = 0
+		}
+	}
+
+	// fail and dict links in BFS order
+	m.nodes[0].fail, m.nodes[0].dict = 0, acNone
+	queue := make([]int32, 0, len(m.nodes))
+	for i := m.nodes[0].edgeStart; i < m.nodes[0].edgeEnd; i++ {
+		to := m.edgeTo[i]
+		m.nodes[to].fail, m.nodes[to].dict = 0, acNone
+		queue = append(queue, to)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for i := m.nodes[cur].edgeStart; i < m.nodes[cur].edgeEnd; i++ {
+			b, to := m.edgeBytes[i], m.edgeTo[i]
+			fail := m.next(m.nodes[cur].fail, b)
+			m.nodes[to].fail = fail
+			if m.nodes[fail].out != acNone {
+				m.nodes[to].dict = fail
+			} else {
+				m.nodes[to].dict = m.nodes[fail].dict
+			}
+			queue = append(queue, to)
+		}
+	}
+	return m
+}
+
+// find calls fn for every occurrence of every word in input in order of end position,
+// overlapped occurrences are all reported, start and end are byte offsets, find stops if fn returns false
+func (m *acMatcher) find(input []byte, fn func(id int, start int, end int) bool) {
+	if m == nil || len(m.nodes) <= 1 {
+		return
+	}
+	cur := int32(0)
+	for i, b := range input {
+		cur = m.next(cur, b)
+		for node := cur; node != acNone; node = m.nodes[node].dict {
+			out := m.nodes[node].out
+			if out == acNone {
+				continue
+			}
+			for _, id := range m.outs[out] {
+				if !fn(int(id), i+1-m.lens[id], i+1) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// next returns the state after reading b at state cur
+func (m *acMatcher) next(cur int32, b byte) int32 {
+	for cur != 0 {
+		if to := m.child(cur, b); to != acNone {
+			return to
+		}
+		cur = m.nodes[cur].fail
+	}
+	return m.root[b]
+}
+
+// child returns the trie child of node by b, acNone if not found
+func (m *acMatcher) child(node int32, b byte) int32 {
+	lo, hi := m.nodes[node].edgeStart, m.nodes[node].edgeEnd
+	for lo < hi { // binary search in sorted edges
+		mid := lo + (hi-lo)/2
+		switch c := m.edgeBytes[mid]; {
+		case c == b:
+			return m.edgeTo[mid]
+		case c < b:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return acNone
+}
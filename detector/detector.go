@@ -7,12 +7,16 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/laojianzi/godlp/conf"
 	"github.com/laojianzi/godlp/header"
+	"github.com/laojianzi/godlp/internal/json"
+	"github.com/laojianzi/godlp/internal/keypath"
 )
 
 // RuleType is different with ResultType, because for input string contains KV object,
@@ -28,15 +32,36 @@ const (
 	VerifyAlgoCreditCard = "CREDITCARD"
 	VerifyAlgoBitcoin    = "BITCOIN"
 	VerifyAlgoDomain     = "DOMAIN"
+	VerifyAlgoEntropy    = "ENTROPY"
+	VerifyAlgoJWT        = "JWT"
 	MaskedCharList       = "*#"
 	DefResultSize        = 4
-	DefContextRange      = 32
+	DefContextRange      = 32 // runes or words on each side of a result, see Verify.Context in conf
+	ContextUnitRune      = "RUNE"
+	ContextUnitWord      = "WORD"
+	ContextDirBoth       = "BOTH"
+	ContextDirBefore     = "BEFORE"
+	ContextDirAfter      = "AFTER"
 	DefIDCardLength      = 18
+	DefEntropy           = 3.0     // min Shannon entropy in bits per character of ENTROPY, see Verify.Entropy in conf
+	ValueGroupName       = "value" // if VReg has a group of this name, the result is the group instead of the match
+)
+
+// default score and boosts of a result, used if they are not set in Score of the rule
+const (
+	DefScoreBase    = 0.5
+	DefScoreContext = 0.3
+	DefScoreAlgo    = 0.3
+	DefScoreKey     = 0.2
 )
 
 // ContextVerifyFunc defines verify by context function
 type ContextVerifyFunc func(*Detector, []byte, *header.DetectResult) bool
 
+// VerifyFunc is a verification algorithm which is used by name in Verify.VAlgo, context is the window
+// around res where CDict and CReg are checked, it must not be modified. false means res is dropped.
+type VerifyFunc func(res *header.DetectResult, context []byte) bool
+
 type Detector struct {
 	rule     conf.RuleItem // rule item in conf
 	RuleType int           // VALUE if there is no KReg and KDict
@@ -45,6 +70,7 @@ type Detector struct {
 	KDict map[string]struct{} // Dict for Key
 	VReg  []*regexp.Regexp    // Regex list for Value
 	VDict []string            // Dict for Value
+	paths keypath.Set         // Path for keys of DetectMap and DetectList, nil means all keys
 	// Filter section in conf
 	BAlgo []string         // algorithm for blacklist, supports MASKED
 	BDict []string         // Dict for blacklist
@@ -53,6 +79,49 @@ type Detector struct {
 	CDict []string         // Dict for Context Verification
 	CReg  []*regexp.Regexp // Regex List for Context Verification
 	VAlgo []string         // algorithm for verify action, such as IDCARD
+	NDict []string         // Dict for Negative Context Verification
+	NReg  []*regexp.Regexp // Regex List for Negative Context Verification
+	// prefilters of VReg, vRegFilters[i] is for VReg[i]
+	vRegFilters []prefilter
+	// require is Require in conf, the value is detected only if it contains one of require
+	require [][]byte
+	// automatons and set compiled from dictionaries
+	vDictAC  *acMatcher          // VDict
+	cDictAC  *acMatcher          // lower case CDict
+	nDictAC  *acMatcher          // lower case NDict
+	bDictSet map[string]struct{} // BDict
+	// kDictWords are KDict entries with words joined, "phone number" and "phone_number" are "phonenumber"
+	kDictWords map[string]struct{}
+	// score of results, Score in conf with defaults
+	scores scoreWeights
+	// window of context verification, Verify.Context in conf with defaults
+	window contextWindow
+	// min entropy of ENTROPY, Verify.Entropy in conf with default
+	entropy float64
+	// counter receives the number of results removed by Filter and Verify, nil means no counting
+	counter Counter
+	// verifiers are VAlgo besides the built-in ones, name -> VerifyFunc, never modified after SetVerifiers
+	verifiers map[string]VerifyFunc
+}
+
+// Counter receives the number of results of a rule removed by Filter and Verify,
+// it must be safe for concurrent use
+type Counter interface {
+	AddFiltered(ruleID int32, n int)
+	AddVerifiedOut(ruleID int32, n int)
+}
+
+// scoreWeights is the base score and boosts of results of a rule
+type scoreWeights struct {
+	base, context, algo, key float64
+}
+
+// contextWindow is the window around a result where context is checked
+type contextWindow struct {
+	size   int
+	byWord bool // size is counted in words instead of runes
+	before bool
+	after  bool
 }
 
 type KVItem struct {
@@ -89,7 +158,9 @@ type API interface {
 func NewDetector(ruleItem conf.RuleItem) (API, error) {
 	obj := new(Detector)
 	obj.rule = ruleItem
-	obj.prepare()
+	if err := obj.prepare(); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
@@ -99,6 +170,36 @@ func (d *Detector) GetRuleInfo() string {
 	return fmt.Sprintf("%+v", d.rule)
 }
 
+// SetCounter sets the counter of results removed by Filter and Verify,
+// it must be called before the detector is used
+func (d *Detector) SetCounter(counter Counter) {
+	d.counter = counter
+}
+
+// WithoutCounter returns a copy of d which does not count results removed by Filter and Verify,
+// the copy shares compiled regexes and dictionaries with d
+func (d *Detector) WithoutCounter() API {
+	out := *d
+	out.counter = nil
+	return &out
+}
+
+// SetVerifiers sets verification algorithms which can be used in Verify.VAlgo besides the built-in ones,
+// verifiers must not be modified after it is set, it must be called before DetectXXX
+func (d *Detector) SetVerifiers(verifiers map[string]VerifyFunc) {
+	d.verifiers = verifiers
+}
+
+// IsBuiltinVerifyAlgo checks whether name is one of the built-in algorithms of Verify.VAlgo
+func IsBuiltinVerifyAlgo(name string) bool {
+	switch name {
+	case VerifyAlgoIDCard, VerifyAlgoAbaRouting, VerifyAlgoCreditCard, VerifyAlgoBitcoin, VerifyAlgoDomain,
+		VerifyAlgoEntropy, VerifyAlgoJWT:
+		return true
+	}
+	return false
+}
+
 // GetRuleID returns RuleID
 func (d *Detector) GetRuleID() int32 {
 	return d.rule.RuleID
@@ -124,32 +225,15 @@ func (d *Detector) UseRegex() bool {
 	return len(d.KReg) > 0 || len(d.VReg) > 0
 }
 
+// IsMultiLine checks whether the rule is evaluated on the whole input instead of line by line
+func (d *Detector) IsMultiLine() bool {
+	return d.IsValue() && d.rule.MultiLine
+}
+
 // DetectBytes detects sensitive info for bytes, is called from Detect()
 func (d *Detector) DetectBytes(inputBytes []byte) ([]*header.DetectResult, error) {
-	results := make([]*header.DetectResult, 0, DefResultSize)
-	for _, reObj := range d.VReg {
-		if ret, err := d.regexDetectBytes(reObj, inputBytes); err == nil {
-			results = append(results, ret...)
-			if len(ret) > 0 && d.rule.InfoType == header.ADDRESS { // Avoid duplicate address types
-				break
-			}
-
-			continue
-		}
-
-		// logger.Errorf(err.Error())
-	}
-	for _, item := range d.VDict {
-		if ret, err := d.dictDetectBytes([]byte(item), inputBytes); err == nil {
-			results = append(results, ret...)
-			continue
-		}
-
-		// logger.Errorf(err.Error())
-	}
-	results = d.filter(results)
-	results = d.verify(inputBytes, results)
-	return results, nil
+	results, err := d.detectBytes(inputBytes)
+	return d.filterScore(results), err
 }
 
 // DetectMap detects for Map, is called from DetectMap() and DetectJSON()
@@ -164,7 +248,7 @@ func (d *Detector) DetectMap(inputMap map[string]string) ([]*header.DetectResult
 		d.doDetectKV(item, &results)
 	}
 
-	return d.filter(results), nil
+	return d.filterScore(d.filter(results)), nil
 }
 
 // DetectList detects for List
@@ -176,24 +260,65 @@ func (d *Detector) DetectList(kvList []*KVItem) ([]*header.DetectResult, error)
 		d.doDetectKV(kvList[i], &results)
 	}
 
-	return d.filter(results), nil
+	return d.filterScore(d.filter(results)), nil
+}
+
+// detectBytes detects sensitive info for bytes, MinScore of the rule is not applied,
+// because results of KV rules get the boost of key later
+func (d *Detector) detectBytes(inputBytes []byte) ([]*header.DetectResult, error) {
+	results := make([]*header.DetectResult, 0, DefResultSize)
+	if !containsAny(inputBytes, d.require) {
+		return results, nil
+	}
+	digits := -1 // counted at most once, only if a prefilter needs it
+	digitsFunc := func() int {
+		if digits < 0 {
+			digits = countDigits(inputBytes)
+		}
+		return digits
+	}
+	for i, reObj := range d.VReg {
+		if !d.vRegFilters[i].match(inputBytes, digitsFunc) { // the regex can not match
+			continue
+		}
+		if ret, err := d.regexDetectBytes(reObj, inputBytes); err == nil {
+			results = append(results, ret...)
+			if len(ret) > 0 && d.rule.InfoType == header.ADDRESS { // Avoid duplicate address types
+				break
+			}
+
+			continue
+		}
+
+		// logger.Errorf(err.Error())
+	}
+	if ret, err := d.dictDetectBytes(inputBytes); err == nil {
+		results = append(results, ret...)
+	}
+	results = d.filter(results)
+	results = d.verify(inputBytes, results)
+	return results, nil
 }
 
 func (d *Detector) doDetectKV(kvItem *KVItem, results *[]*header.DetectResult) {
+	if d.paths != nil && !d.paths.Match(kvItem.Key) { // out of Path of the rule
+		return
+	}
 	// inK may be a path of json object
 	lastKey, ifExtracted := d.getLastKey(kvItem.Key)
 	resultType := ResultTypeValue
 	if d.IsKV() { // nolint: nestif
 		// key rules check
 		// Dict rules first, then regex rule
-		_, hit := d.KDict[lastKey]
+		hit := d.hitKDict(lastKey)
 		if (!hit) && ifExtracted {
-			_, hit = d.KDict[kvItem.Key]
+			_, hit = d.KDict[strings.ToLower(kvItem.Key)]
 		}
 
 		if !hit {
+			loLastKey := []byte(strings.ToLower(lastKey))
 			for _, re := range d.KReg {
-				if re.Match([]byte(lastKey)) {
+				if re.Match(loLastKey) {
 					hit = true
 					break
 				}
@@ -209,7 +334,10 @@ func (d *Detector) doDetectKV(kvItem *KVItem, results *[]*header.DetectResult) {
 			if res, err := d.createKVResult(kvItem.Key, kvItem.Value); err == nil {
 				res.ByteStart += kvItem.Start
 				res.ByteEnd += kvItem.Start
-				*results = append(*results, d.verify([]byte(kvItem.Value), []*header.DetectResult{res})...)
+				for _, res := range d.verify([]byte(kvItem.Value), []*header.DetectResult{res}) {
+					d.addScore(res, d.scores.key)
+					*results = append(*results, res)
+				}
 			}
 
 			return
@@ -218,21 +346,22 @@ func (d *Detector) doDetectKV(kvItem *KVItem, results *[]*header.DetectResult) {
 		resultType = ResultTypeKv
 	}
 
-	vResults, err := d.DetectBytes([]byte(kvItem.Value))
+	// results are verified by detectBytes at their positions in the value, Score is computed once there
+	vResults, err := d.detectBytes([]byte(kvItem.Value))
 	if err != nil {
 		return
 	}
 
-	vNewResults := make([]*header.DetectResult, len(vResults))
-	for i, res := range vResults {
+	for _, res := range vResults {
 		res.ResultType = resultType
 		res.Key = kvItem.Key
 		res.ByteStart += kvItem.Start
 		res.ByteEnd += kvItem.Start
-		vNewResults[i] = res
+		if resultType == ResultTypeKv { // key rule is hit
+			d.addScore(res, d.scores.key)
+		}
+		*results = append(*results, res)
 	}
-
-	*results = append(*results, d.verify([]byte(kvItem.Value), vNewResults)...)
 }
 
 // Close release detector object
@@ -245,41 +374,109 @@ func (d *Detector) Close() {
 	d.releaseReg(d.KReg)
 	d.KReg = nil
 	d.VDict = nil
+	d.vDictAC = nil
+	d.vRegFilters = nil
+	d.require = nil
 	d.releaseReg(d.VReg)
 	d.VReg = nil
 
 	// Filter section
 	d.BAlgo = nil
 	d.BDict = nil
+	d.bDictSet = nil
 	d.releaseReg(d.BReg)
 	d.BReg = nil
 
 	// Verify section
 	d.CDict = nil
+	d.cDictAC = nil
 	d.releaseReg(d.CReg)
 	d.CReg = nil
 	d.VAlgo = nil
+	d.NDict = nil
+	d.nDictAC = nil
+	d.releaseReg(d.NReg)
+	d.NReg = nil
 }
 
 // private func
 
 // prepare will prepare detector object from rule
-func (d *Detector) prepare() {
+func (d *Detector) prepare() (err error) {
 	// Detect
-	d.KReg = d.preCompile(d.rule.Detect.KReg)
+	if d.KReg, err = d.preCompile(d.rule.Detect.KReg); err != nil {
+		return err
+	}
 	d.KDict = lowerStringList2Map(d.rule.Detect.KDict)
-	d.VReg = d.preCompile(d.rule.Detect.VReg)
+	d.kDictWords = make(map[string]struct{}, len(d.rule.Detect.KDict))
+	for _, word := range d.rule.Detect.KDict {
+		if tokens := keypath.Tokens(word); len(tokens) > 0 {
+			d.kDictWords[strings.Join(tokens, "")] = struct{}{}
+		}
+	}
+	if d.VReg, err = d.preCompile(d.rule.Detect.VReg); err != nil {
+		return err
+	}
+	d.vRegFilters = make([]prefilter, len(d.rule.Detect.VReg))
+	for i, reStr := range d.rule.Detect.VReg {
+		d.vRegFilters[i] = newPrefilter(reStr)
+	}
+	if d.paths, err = keypath.CompileSet(d.rule.Detect.Path); err != nil {
+		return err
+	}
+	d.require = stringList2Bytes(d.rule.Require, false)
 	d.VDict = d.rule.Detect.VDict
+	d.vDictAC = newACMatcher(stringList2Bytes(d.VDict, false))
 
 	// Filter
-	d.BReg = d.preCompile(d.rule.Filter.BReg)
+	if d.BReg, err = d.preCompile(d.rule.Filter.BReg); err != nil {
+		return err
+	}
 	d.BAlgo = d.rule.Filter.BAlgo
 	d.BDict = d.rule.Filter.BDict
+	d.bDictSet = make(map[string]struct{}, len(d.BDict))
+	for _, word := range d.BDict {
+		d.bDictSet[word] = struct{}{}
+	}
 	// Verify
-	d.CReg = d.preCompile(d.rule.Verify.CReg)
+	if d.CReg, err = d.preCompile(d.rule.Verify.CReg); err != nil {
+		return err
+	}
 	d.CDict = d.rule.Verify.CDict
+	d.cDictAC = newACMatcher(stringList2Bytes(d.CDict, true))
 	d.VAlgo = d.rule.Verify.VAlgo
+	d.entropy = defScore(d.rule.Verify.Entropy, DefEntropy)
+	if d.NReg, err = d.preCompile(d.rule.Verify.NReg); err != nil {
+		return err
+	}
+	d.NDict = d.rule.Verify.NDict
+	d.nDictAC = newACMatcher(stringList2Bytes(d.NDict, true))
+	ctxConf := d.rule.Verify.Context
+	d.window = contextWindow{
+		size:   int(ctxConf.Range),
+		byWord: ctxConf.Unit == ContextUnitWord,
+		before: ctxConf.Direction != ContextDirAfter,
+		after:  ctxConf.Direction != ContextDirBefore,
+	}
+	if d.window.size <= 0 {
+		d.window.size = DefContextRange
+	}
+	d.scores = scoreWeights{
+		base:    defScore(d.rule.Score.Base, DefScoreBase),
+		context: defScore(d.rule.Score.Context, DefScoreContext),
+		algo:    defScore(d.rule.Score.Algo, DefScoreAlgo),
+		key:     defScore(d.rule.Score.Key, DefScoreKey),
+	}
 	d.setRuleType()
+	return nil
+}
+
+// defScore returns def if score is not set
+func defScore(score float64, def float64) float64 {
+	if score == 0 {
+		return def
+	}
+	return score
 }
 
 // setRuleType set RuleType based on K V in detect section of config
@@ -299,17 +496,17 @@ func (d *Detector) releaseReg(list []*regexp.Regexp) {
 }
 
 // preCompile compiles regex string list then return regex list
-func (d *Detector) preCompile(reList []string) []*regexp.Regexp {
+func (d *Detector) preCompile(reList []string) ([]*regexp.Regexp, error) {
 	list := make([]*regexp.Regexp, 0, DefResultSize)
 	for _, reStr := range reList {
-		if re, err := regexp.Compile(reStr); err == nil {
-			list = append(list, re)
-			continue
+		re, err := regexp.Compile(reStr)
+		if err != nil {
+			return nil, fmt.Errorf("%w, RuleID:%d, regex %q, %s",
+				header.ErrRegexCompileFailed, d.rule.RuleID, reStr, err.Error())
 		}
-
-		// logger.Errorf("Regex %s ,error: %w", reStr, err)
+		list = append(list, re)
 	}
-	return list
+	return list, nil
 }
 
 // preToLower modify dictList to lower case
@@ -334,12 +531,35 @@ func lowerStringList2Map(dictList []string) map[string]struct{} {
 	return m
 }
 
+// stringList2Bytes converts dictList into bytes list, words are converted into lower case if toLower is true
+func stringList2Bytes(dictList []string, toLower bool) [][]byte {
+	out := make([][]byte, len(dictList))
+	for i, word := range dictList {
+		if toLower {
+			word = strings.ToLower(word)
+		}
+		out[i] = []byte(word)
+	}
+	return out
+}
+
 // regexDetectBytes use regex to detect input bytes
 func (d *Detector) regexDetectBytes(re *regexp.Regexp, inputBytes []byte) ([]*header.DetectResult, error) {
 	if re == nil {
 		return nil, header.ErrReEmpty
 	}
 	results := make([]*header.DetectResult, 0, DefResultSize)
+	if group := re.SubexpIndex(ValueGroupName); group > 0 { // only the group is sensitive, such as a bearer token
+		for _, pos := range re.FindAllSubmatchIndex(inputBytes, -1) {
+			if pos[2*group] < 0 { // the group is optional and not matched
+				continue
+			}
+			if res, err := d.createValueResult(inputBytes, pos[2*group:2*group+2]); err == nil {
+				results = append(results, res)
+			}
+		}
+		return results, nil
+	}
 	if ret := re.FindAllIndex(inputBytes, -1); ret != nil {
 		for i := range ret {
 			pos := ret[i]
@@ -351,24 +571,28 @@ func (d *Detector) regexDetectBytes(re *regexp.Regexp, inputBytes []byte) ([]*he
 	return results, nil
 }
 
-// dictDetectBytes finds whether word in input bytes
-func (d *Detector) dictDetectBytes(word []byte, inputBytes []byte) ([]*header.DetectResult, error) {
+// dictDetectBytes finds words of VDict in input bytes by Aho-Corasick automaton
+func (d *Detector) dictDetectBytes(inputBytes []byte) ([]*header.DetectResult, error) {
 	results := make([]*header.DetectResult, 0, DefResultSize)
-	current := inputBytes
-	currStart := 0
-	for len(current) > 0 {
-		start := bytes.Index(current, word)
-		if start == -1 { // not found
-			break
-		} else { // found, then move forward
-			currStart += start
-			end := currStart + len(word)
-			pos := []int{currStart, end}
-			if res, err := d.createValueResult(inputBytes, pos); err == nil {
+	if len(d.VDict) == 0 {
+		return results, nil
+	}
+	// occurrences of a word do not overlap, the leftmost one wins
+	posMap := make(map[int][]int)
+	d.vDictAC.find(inputBytes, func(id int, start int, end int) bool {
+		list := posMap[id]
+		if len(list) == 0 || list[len(list)-1] <= start {
+			posMap[id] = append(list, start, end)
+		}
+		return true
+	})
+	// results are in order of words, then positions
+	for id := range d.VDict {
+		list := posMap[id]
+		for i := 0; i+1 < len(list); i += 2 {
+			if res, err := d.createValueResult(inputBytes, list[i:i+2]); err == nil {
 				results = append(results, res)
 			}
-			current = inputBytes[end:]
-			currStart = end
 		}
 	}
 	return results, nil
@@ -407,6 +631,7 @@ func (d *Detector) newResult() *header.DetectResult {
 	ret.CnName = d.rule.CnName
 	ret.ExtInfo = d.rule.ExtInfo
 	ret.Level = d.rule.Level
+	ret.Score = d.scores.base
 	return ret
 }
 
@@ -422,18 +647,16 @@ func (d *Detector) filter(in []*header.DetectResult) []*header.DetectResult {
 		out = append(out, res)
 	}
 
+	if d.counter != nil && len(out) < len(in) {
+		d.counter.AddFiltered(d.rule.RuleID, len(in)-len(out))
+	}
 	return out
 }
 
 func (d *Detector) filterBDict(text string) bool {
-	for _, word := range d.BDict {
-		// Found in BlackList BDict
-		if strings.Compare(text, word) == 0 {
-			return true
-		}
-	}
-
-	return false
+	// Found in BlackList BDict
+	_, ok := d.bDictSet[text]
+	return ok
 }
 
 func (d *Detector) filterBReg(text string) bool {
@@ -466,17 +689,33 @@ func IsMasked(in string) bool {
 	return pos != -1 // found mask char
 }
 
-// verify use verify config to check results
+// verify use verify config to check results, Score of results is computed from the base score here,
+// results which fail the check are dropped, or kept without the boost if Score.Soft is set,
+// results with negative context are always dropped
 func (d *Detector) verify(inputBytes []byte, in []*header.DetectResult) []*header.DetectResult {
 	out := make([]*header.DetectResult, 0, DefResultSize)
 	markList := make([]bool, len(in))
 	for i := range markList {
 		markList[i] = true
+		in[i].Score = d.scores.base
+	}
+
+	if len(d.NDict) != 0 || len(d.NReg) != 0 { // need negative context check
+		for i, res := range in {
+			if !d.verifyByNegativeContext(inputBytes, res) {
+				markList[i] = false
+			}
+		}
 	}
 
 	if len(d.CDict) != 0 || len(d.CReg) != 0 { // need context check
 		for i, res := range in {
-			if !d.verifyByContext(inputBytes, res) { // check failed
+			if !markList[i] {
+				continue
+			}
+			if d.verifyByContext(inputBytes, res) {
+				d.addScore(res, d.scores.context)
+			} else if !d.rule.Score.Soft { // check failed
 				markList[i] = false
 			}
 		}
@@ -484,7 +723,14 @@ func (d *Detector) verify(inputBytes []byte, in []*header.DetectResult) []*heade
 
 	if len(d.VAlgo) != 0 {
 		// need verify algorithm check
-		d.verifyAlgo(in, markList)
+		passList := d.verifyAlgo(inputBytes, in, append([]bool(nil), markList...))
+		for i, pass := range passList {
+			if pass {
+				d.addScore(in[i], d.scores.algo)
+			} else if !d.rule.Score.Soft {
+				markList[i] = false
+			}
+		}
 	}
 
 	for i, need := range markList {
@@ -493,11 +739,36 @@ func (d *Detector) verify(inputBytes []byte, in []*header.DetectResult) []*heade
 		}
 	}
 
+	if d.counter != nil && len(out) < len(in) {
+		d.counter.AddVerifiedOut(d.rule.RuleID, len(in)-len(out))
+	}
 	return out
 }
 
-// verifyAlgo verify algorithm check
-func (d *Detector) verifyAlgo(in []*header.DetectResult, markList []bool) []bool {
+// addScore adds boost to Score of res, Score is at most 1
+func (d *Detector) addScore(res *header.DetectResult, boost float64) {
+	res.Score = math.Min(math.Round((res.Score+boost)*1e4)/1e4, 1)
+}
+
+// filterScore drops results whose Score is lower than MinScore of the rule
+func (d *Detector) filterScore(in []*header.DetectResult) []*header.DetectResult {
+	if d.rule.MinScore <= 0 {
+		return in
+	}
+	out := in[:0]
+	for _, res := range in {
+		if res.Score >= d.rule.MinScore {
+			out = append(out, res)
+		}
+	}
+	if d.counter != nil && len(out) < len(in) {
+		d.counter.AddVerifiedOut(d.rule.RuleID, len(in)-len(out))
+	}
+	return out
+}
+
+// verifyAlgo verify algorithm check, algorithms which are not built-in are found in verifiers
+func (d *Detector) verifyAlgo(inputBytes []byte, in []*header.DetectResult, markList []bool) []bool {
 	for i, res := range in {
 		if !markList[i] {
 			continue
@@ -525,6 +796,18 @@ func (d *Detector) verifyAlgo(in []*header.DetectResult, markList []bool) []bool
 				if !d.verifyByDomain(res) {
 					markList[i] = false
 				}
+			case VerifyAlgoEntropy:
+				if !d.verifyByEntropy(res) {
+					markList[i] = false
+				}
+			case VerifyAlgoJWT:
+				if !d.verifyByJWT(res) {
+					markList[i] = false
+				}
+			default:
+				if fn, ok := d.verifiers[algo]; ok && !fn(res, d.contextWindowOf(inputBytes, res)) {
+					markList[i] = false
+				}
 			}
 		}
 	}
@@ -534,34 +817,103 @@ func (d *Detector) verifyAlgo(in []*header.DetectResult, markList []bool) []bool
 
 // verifyByContext check around context to decide whether res is accuracy
 func (d *Detector) verifyByContext(inputBytes []byte, res *header.DetectResult) bool {
-	st := res.ByteStart - DefContextRange
+	return d.matchContext(d.contextOf(inputBytes, res), d.cDictAC, d.CReg)
+}
+
+// verifyByNegativeContext checks the same context as verifyByContext, false is returned if NDict or NReg is hit
+func (d *Detector) verifyByNegativeContext(inputBytes []byte, res *header.DetectResult) bool {
+	return !d.matchContext(d.contextOf(inputBytes, res), d.nDictAC, d.NReg)
+}
+
+// contextOf returns the lower case context around res
+func (d *Detector) contextOf(inputBytes []byte, res *header.DetectResult) []byte {
+	// to lower
+	return bytes.ToLower(d.contextWindowOf(inputBytes, res))
+}
+
+// contextWindowOf returns the context around res in inputBytes, it is res and the window before and after it
+func (d *Detector) contextWindowOf(inputBytes []byte, res *header.DetectResult) []byte {
+	st := res.ByteStart
 	if st < 0 {
 		st = 0
 	}
-	ed := res.ByteEnd + DefContextRange
+	ed := res.ByteEnd
 	lenInput := len(inputBytes)
 	if ed > lenInput {
 		ed = lenInput
 	}
-	subInput := inputBytes[st:ed]
-	// to lower
-	subInput = bytes.ToLower(subInput)
-	for _, word := range d.CDict {
-		if len(word) == 0 {
-			continue
+	if st > ed {
+		st = ed
+	}
+	if d.window.before {
+		st = d.window.start(inputBytes, st)
+	}
+	if d.window.after {
+		ed = d.window.end(inputBytes, ed)
+	}
+	return inputBytes[st:ed:ed]
+}
+
+// start returns the start of the window before pos, it stops at a line boundary
+func (w contextWindow) start(in []byte, pos int) int {
+	n, inWord := 0, false
+	for pos > 0 {
+		r, width := utf8.DecodeLastRune(in[:pos])
+		if !w.count(r, &n, &inWord) {
+			break
 		}
-		wordBytes := []byte(strings.ToLower(word))
-		pos := bytes.Index(subInput, wordBytes)
-		for start := 0; pos != -1; pos = bytes.Index(subInput[start:], wordBytes) {
-			if d.isWholeWord(subInput[start:], wordBytes, pos) {
-				return true
-			}
-			start += pos + len(word)
+		pos -= width
+	}
+	return pos
+}
+
+// end returns the end of the window after pos, it stops at a line boundary
+func (w contextWindow) end(in []byte, pos int) int {
+	n, inWord := 0, false
+	for pos < len(in) {
+		r, width := utf8.DecodeRune(in[pos:])
+		if !w.count(r, &n, &inWord) {
+			break
 		}
+		pos += width
 	}
+	return pos
+}
 
+// count counts r into n, which is the number of runes or words in the window so far,
+// false is returned if r is out of the window
+func (w contextWindow) count(r rune, n *int, inWord *bool) bool {
+	if r == '\n' || r == '\r' {
+		return false
+	}
+	if !w.byWord {
+		*n++
+		return *n <= w.size
+	}
+	// a word is a run of letters and digits, separators between words are in the window
+	if isSep := unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r); !isSep {
+		if !*inWord {
+			*n++
+			*inWord = true
+		}
+	} else {
+		*inWord = false
+	}
+	return *n <= w.size
+}
+
+// matchContext checks whether one of whole words of dictAC or one of regs is found in subInput
+func (d *Detector) matchContext(subInput []byte, dictAC *acMatcher, regs []*regexp.Regexp) bool {
 	var found bool
-	for _, re := range d.CReg {
+	dictAC.find(subInput, func(_ int, start int, end int) bool {
+		found = d.isWholeWord(subInput, subInput[start:end], start)
+		return !found
+	})
+	if found {
+		return true
+	}
+
+	for _, re := range regs {
 		if re.Match(subInput) {
 			found = true
 			break
@@ -781,6 +1133,69 @@ func (d *Detector) verifyByDomain(res *header.DetectResult) bool {
 	return false
 }
 
+// verifyByEntropy checks whether Shannon entropy of the result is at least d.entropy bits per character,
+// random secrets such as access keys have high entropy, words and repeated characters do not
+func (d *Detector) verifyByEntropy(res *header.DetectResult) bool {
+	return ShannonEntropy(res.Text) >= d.entropy
+}
+
+// ShannonEntropy returns Shannon entropy of s in bits per character
+func ShannonEntropy(s string) float64 {
+	counts := make(map[rune]int, len(s))
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// verifyByJWT checks the structure of a JSON Web Token, header.payload.signature in base64url,
+// the header must be a JSON object with "alg"
+func (d *Detector) verifyByJWT(res *header.DetectResult) bool {
+	parts := strings.Split(res.Text, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[0], "="))
+	if err != nil {
+		return false
+	}
+	var jwtHeader struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerData, &jwtHeader); err != nil || len(jwtHeader.Alg) == 0 {
+		return false
+	}
+	_, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	return err == nil
+}
+
+// hitKDict checks whether key is hit by KDict, words of key are split by naming styles, the key is hit if
+// its last words are an entry of KDict, such as "phone number" is hit by "user.phoneNumber" and "PHONE_NUMBER"
+func (d *Detector) hitKDict(key string) bool {
+	if _, hit := d.KDict[strings.ToLower(key)]; hit {
+		return true
+	}
+	if len(d.kDictWords) == 0 {
+		return false
+	}
+	tokens := keypath.Tokens(key)
+	suffix := ""
+	for i := len(tokens) - 1; i >= 0; i-- {
+		suffix = tokens[i] + suffix
+		if _, hit := d.kDictWords[suffix]; hit {
+			return true
+		}
+	}
+	return false
+}
+
 // getLastKey extracts last key from path
 func (d *Detector) getLastKey(path string) (string, bool) {
 	sz := len(path)
@@ -1,8 +1,10 @@
 package detector_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/laojianzi/godlp/conf"
 	"github.com/laojianzi/godlp/detector"
 )
 
@@ -32,3 +34,274 @@ func TestIsMasked(t *testing.T) {
 		})
 	}
 }
+
+func TestDetector_DetectBytesDict(t *testing.T) {
+	rule := conf.RuleItem{RuleID: 1, InfoType: "TEST"}
+	rule.Detect.VDict = []string{"abab", "ab", "b", "中文", "ab"}
+	rule.Filter.BDict = []string{"b"}
+	obj, err := detector.NewDetector(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "ababab 中文ab"
+	results, err := obj.DetectBytes([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// occurrences of a word do not overlap, results are in order of words, "b" is in BDict
+	want := [][2]int{{0, 4}, {0, 2}, {2, 4}, {4, 6}, {13, 15}, {7, 13}, {0, 2}, {2, 4}, {4, 6}, {13, 15}}
+	if len(results) != len(want) {
+		t.Fatalf("DetectBytes() got %d results, want %d", len(results), len(want))
+	}
+	for i, res := range results {
+		if got := [2]int{res.ByteStart, res.ByteEnd}; got != want[i] || res.Text != input[got[0]:got[1]] {
+			t.Errorf("DetectBytes() result[%d] got = %v %q, want = %v", i, got, res.Text, want[i])
+		}
+	}
+}
+
+func TestDetector_DetectBytesPrefilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		vReg    []string
+		require []string
+		input   string
+		want    []string
+	}{
+		{"digits", []string{`\b1\d{10}\b`}, nil, "1861234123 and 18612341234", []string{"18612341234"}},
+		{"not enough digits", []string{`\b1\d{10}\b`}, nil, "1861234123", nil},
+		{"literal", []string{`\w+@\w+\.com`}, nil, "abc@abc.com", []string{"abc@abc.com"}},
+		{"no literal", []string{`\w+@\w+\.com`}, nil, "abc.abc.com", nil},
+		{"alternation", []string{`(北京|上海)市`}, nil, "我在上海市", []string{"上海市"}},
+		{"fold case", []string{`(?i)secret`}, nil, "SeCrEt", []string{"SeCrEt"}},
+		{"require", []string{`\w+`}, []string{"="}, "abc", nil},
+		{"require hit", []string{`\w+`}, []string{"="}, "abc=", []string{"abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := conf.RuleItem{RuleID: 1, InfoType: "TEST", Require: tt.require}
+			rule.Detect.VReg = tt.vReg
+			obj, err := detector.NewDetector(rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := obj.DetectBytes([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(results))
+			for _, res := range results {
+				got = append(got, res.Text)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && strings.Join(got, ",") != strings.Join(tt.want, ",")) {
+				t.Errorf("DetectBytes() got = %v, want = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetector_Score(t *testing.T) {
+	rule := conf.RuleItem{RuleID: 1, InfoType: "TEST"}
+	rule.Detect.VReg = []string{`\d{6}`}
+	rule.Verify.CDict = []string{"code"}
+	rule.Score.Soft = true
+	obj, err := detector.NewDetector(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "code 123456" + strings.Repeat(" ", 40) + "654321"
+	results, err := obj.DetectBytes([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second one fails the context check, it is kept without the boost because of Soft
+	want := []float64{detector.DefScoreBase + detector.DefScoreContext, detector.DefScoreBase}
+	if len(results) != len(want) {
+		t.Fatalf("DetectBytes() got %d results, want %d", len(results), len(want))
+	}
+	for i, res := range results {
+		if res.Score != want[i] {
+			t.Errorf("DetectBytes() result[%d] got score = %v, want = %v", i, res.Score, want[i])
+		}
+	}
+
+	// KV results get the boost of key, MinScore drops results with lower score
+	rule.Detect.KDict = []string{"code"}
+	rule.MinScore = 0.9
+	if obj, err = detector.NewDetector(rule); err != nil {
+		t.Fatal(err)
+	}
+	results, err = obj.DetectList([]*detector.KVItem{
+		{Key: "code", Value: "code 123456"}, {Key: "code", Value: "654321"}, {Key: "other", Value: "code 123456"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Text != "123456" || results[0].Score != 1 {
+		t.Errorf("DetectList() got %d results, want 123456 with score 1", len(results))
+	}
+}
+
+func TestDetector_ScoreKVContext(t *testing.T) {
+	rule := conf.RuleItem{RuleID: 1, InfoType: "TEST"}
+	rule.Detect.KDict = []string{"code"}
+	rule.Detect.VReg = []string{`\d{6}`}
+	rule.Verify.CDict = []string{"tel"}
+	rule.Score.Base, rule.Score.Context, rule.Score.Key, rule.Score.Soft = 0.4, 0.3, 0.1, true
+	obj, err := detector.NewDetector(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the value is extracted from text, its context is checked once at the position in the value
+	value := "tel 123456" + strings.Repeat(" ", 60)
+	results, err := obj.DetectList([]*detector.KVItem{{Key: "code", Value: value, Start: 40, End: 40 + len(value)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ByteStart != 44 {
+		t.Fatalf("DetectList() got %d results, want 123456 at 44", len(results))
+	}
+	if results[0].Score != 0.8 {
+		t.Errorf("DetectList() got score = %v, want 0.8 with boosts of context and key", results[0].Score)
+	}
+}
+
+func TestDetector_NegativeContext(t *testing.T) {
+	rule := conf.RuleItem{RuleID: 1, InfoType: "PHONE"}
+	rule.Detect.VReg = []string{`1\d{10}`}
+	rule.Verify.NDict = []string{"order_id", "trace"}
+	rule.Verify.NReg = []string{`timestamp\s*[:=]`}
+	obj, err := detector.NewDetector(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"phone: 18612341234", 1},
+		{"order_id: 18612341234", 0},
+		{"ORDER_ID=18612341234", 0},
+		{"my_order_ids 18612341234", 1}, // not a whole word
+		{"Timestamp: 16612341234", 0},
+		{"trace" + strings.Repeat(" ", 40) + "18612341234", 1}, // out of the context range
+	}
+	for _, tt := range tests {
+		results, err := obj.DetectBytes([]byte(tt.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != tt.want {
+			t.Errorf("DetectBytes(%q) got %d results, want %d", tt.input, len(results), tt.want)
+		}
+	}
+}
+
+func TestDetector_ContextWindow(t *testing.T) {
+	newRule := func(size int32, unit string, direction string) conf.RuleItem {
+		rule := conf.RuleItem{RuleID: 1, InfoType: "ID"}
+		rule.Detect.VReg = []string{`\d{6}`}
+		rule.Verify.CDict = []string{"id number", "身份证"}
+		rule.Verify.Context.Range = size
+		rule.Verify.Context.Unit = unit
+		rule.Verify.Context.Direction = direction
+		return rule
+	}
+	tests := []struct {
+		name  string
+		rule  conf.RuleItem
+		input string
+		want  int
+	}{
+		{"default", newRule(0, "", ""), "ID number: 123456", 1},
+		{"before", newRule(0, "", detector.ContextDirBefore), "ID number: 123456", 1},
+		{"before only", newRule(0, "", detector.ContextDirBefore), "123456 is not an ID number", 0},
+		{"after only", newRule(0, "", detector.ContextDirAfter), "ID number: 123456", 0},
+		{"runes", newRule(5, "", detector.ContextDirBefore), "身份证号：123456", 1},
+		{"runes out of range", newRule(4, "", detector.ContextDirBefore), "身份证号：123456", 0},
+		{"words", newRule(2, detector.ContextUnitWord, ""), "ID number is: 123456", 0},
+		{"words in range", newRule(3, detector.ContextUnitWord, ""), "ID number is: 123456", 1},
+		{"line boundary", newRule(0, "", ""), "ID number\n123456", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := detector.NewDetector(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := obj.DetectBytes([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != tt.want {
+				t.Errorf("DetectBytes(%q) got %d results, want %d", tt.input, len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestDetector_KDictWords(t *testing.T) {
+	rule := conf.RuleItem{RuleID: 1, InfoType: "PHONE"}
+	rule.Detect.KDict = []string{"phone number", "电话"}
+	obj, err := detector.NewDetector(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"phone number", true},
+		{"phoneNumber", true},
+		{"PHONE_NUMBER", true},
+		{"phone-number", true},
+		{"phonenumber", true},
+		{"user.mobilePhoneNumber", true},
+		{"/user/contact[0]/phone_number", true},
+		{"联系电话", false},
+		{"联系_电话", true},
+		{"phoneNumberType", false},
+		{"phone", false},
+	}
+	for _, tt := range tests {
+		results, err := obj.DetectMap(map[string]string{tt.key: "18612341234"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(results) == 1; got != tt.want {
+			t.Errorf("DetectMap() with key %q got hit = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestDetector_Entropy(t *testing.T) {
+	if got := detector.ShannonEntropy("aaaa"); got != 0 {
+		t.Errorf("ShannonEntropy() got = %v, want 0", got)
+	}
+	if got := detector.ShannonEntropy("abcd"); got != 2 {
+		t.Errorf("ShannonEntropy() got = %v, want 2", got)
+	}
+
+	// only the group named value is the result, Entropy is the threshold of ENTROPY
+	rule := conf.RuleItem{RuleID: 1, InfoType: "TOKEN"}
+	rule.Detect.VReg = []string{`token=(?P<value>\w+)`}
+	rule.Verify.VAlgo = []string{detector.VerifyAlgoEntropy}
+	rule.Verify.Entropy = 2.5
+	obj, err := detector.NewDetector(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := obj.DetectBytes([]byte("token=abcd token=aZ3kQ9xW"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Text != "aZ3kQ9xW" || results[0].ByteStart != 17 {
+		t.Errorf("DetectBytes() got %d results, want aZ3kQ9xW at 17", len(results))
+	}
+}
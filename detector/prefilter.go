@@ -0,0 +1,212 @@
+// Package detector prefilter.go implements literal prefilter of regexes, a regex is skipped
+// if the input can not match it, such as no '@' for EMAIL or fewer than 11 digits for PHONE
+package detector
+
+import (
+	"bytes"
+	"regexp/syntax"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defPrefilterMaxLits  = 64 // max literals of a prefilter, more alternatives make prefilter useless
+	defPrefilterMaxClass = 8  // max runes of a char class which is used as literals
+)
+
+// prefilter is the prerequisite of a regex derived from its syntax tree, every match of the regex
+// contains one of lits and at least digits ASCII digits, the zero value matches any input
+type prefilter struct {
+	lits   [][]byte
+	digits int
+}
+
+// newPrefilter derives prefilter from regex string, the zero value is returned if reStr can not be parsed
+func newPrefilter(reStr string) prefilter {
+	re, err := syntax.Parse(reStr, syntax.Perl)
+	if err != nil {
+		return prefilter{}
+	}
+	lits, _ := requiredLits(re)
+	return prefilter{lits: uniqueLits(lits), digits: minDigits(re)}
+}
+
+// match checks whether input may match the regex, countDigits returns the number of digits in input
+func (p *prefilter) match(input []byte, countDigits func() int) bool {
+	if p.digits > 0 && countDigits() < p.digits {
+		return false
+	}
+	return containsAny(input, p.lits)
+}
+
+// containsAny checks whether input contains one of lits, true is returned if lits is empty
+func containsAny(input []byte, lits [][]byte) bool {
+	if len(lits) == 0 {
+		return true
+	}
+	for _, lit := range lits {
+		if bytes.Contains(input, lit) {
+			return true
+		}
+	}
+	return false
+}
+
+// countDigits returns the number of ASCII digits in input
+func countDigits(input []byte) int {
+	cnt := 0
+	for _, c := range input {
+		if c >= '0' && c <= '9' {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// requiredLits returns literals that every match of re contains one of them, ok is false if there is no such set
+func requiredLits(re *syntax.Regexp) (lits [][]byte, ok bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 && hasLetter(re.Rune) {
+			return nil, false
+		}
+		return [][]byte{[]byte(string(re.Rune))}, true
+	case syntax.OpCharClass:
+		return classLits(re)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLits(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return nil, false
+		}
+		return requiredLits(re.Sub[0])
+	case syntax.OpConcat:
+		// the most selective one: longest shortest literal, then fewest literals
+		for _, sub := range re.Sub {
+			subLits, subOk := requiredLits(sub)
+			if subOk && (!ok || betterLits(subLits, lits)) {
+				lits, ok = subLits, true
+			}
+		}
+		return lits, ok
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			subLits, subOk := requiredLits(sub)
+			if !subOk || len(lits)+len(subLits) > defPrefilterMaxLits {
+				return nil, false
+			}
+			lits = append(lits, subLits...)
+		}
+		return lits, len(lits) > 0
+	}
+	return nil, false
+}
+
+// classLits returns every rune of a small char class as literals
+func classLits(re *syntax.Regexp) ([][]byte, bool) {
+	size := 0
+	for i := 0; i+1 < len(re.Rune); i += 2 {
+		size += int(re.Rune[i+1]-re.Rune[i]) + 1
+		if size > defPrefilterMaxClass {
+			return nil, false
+		}
+	}
+	lits := make([][]byte, 0, size)
+	for i := 0; i+1 < len(re.Rune); i += 2 {
+		for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+			buf := make([]byte, utf8.RuneLen(r))
+			utf8.EncodeRune(buf, r)
+			lits = append(lits, buf)
+		}
+	}
+	return lits, len(lits) > 0
+}
+
+// uniqueLits removes duplicated literals
+func uniqueLits(lits [][]byte) [][]byte {
+	out := lits[:0]
+	seen := make(map[string]struct{}, len(lits))
+	for _, lit := range lits {
+		if _, ok := seen[string(lit)]; !ok {
+			seen[string(lit)] = struct{}{}
+			out = append(out, lit)
+		}
+	}
+	return out
+}
+
+// betterLits checks whether a is more selective than b
+func betterLits(a [][]byte, b [][]byte) bool {
+	minA, minB := minLen(a), minLen(b)
+	if minA != minB {
+		return minA > minB
+	}
+	return len(a) < len(b)
+}
+
+// minLen returns the length of the shortest literal
+func minLen(lits [][]byte) int {
+	out := -1
+	for _, lit := range lits {
+		if out == -1 || len(lit) < out {
+			out = len(lit)
+		}
+	}
+	return out
+}
+
+// hasLetter checks whether runes contain a letter which has other cases
+func hasLetter(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.SimpleFold(r) != r {
+			return true
+		}
+	}
+	return false
+}
+
+// minDigits returns the min number of ASCII digits in every match of re
+func minDigits(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		cnt := 0
+		for _, r := range re.Rune {
+			if r >= '0' && r <= '9' {
+				cnt++
+			}
+		}
+		return cnt
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return 0
+		}
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i] < '0' || re.Rune[i+1] > '9' {
+				return 0
+			}
+		}
+		return 1
+	case syntax.OpCapture, syntax.OpPlus:
+		return minDigits(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min * minDigits(re.Sub[0])
+	case syntax.OpConcat:
+		sum := 0
+		for _, sub := range re.Sub {
+			sum += minDigits(sub)
+		}
+		return sum
+	case syntax.OpAlternate:
+		out := -1
+		for _, sub := range re.Sub {
+			if cnt := minDigits(sub); out == -1 || cnt < out {
+				out = cnt
+			}
+		}
+		if out < 0 {
+			return 0
+		}
+		return out
+	}
+	return 0
+}
@@ -14,8 +14,8 @@ import (
 func Example() {
 	logger.SetLogger(&exampleLogger{})
 	caller := "replace.your.caller"
-	// 使用时请将NewEngine()放到循环外，每个线程独立一个Engine Object
-	// remove NewEngine() outside for loop, and one Engine Object one thread/goroutine
+	// 使用时请将NewEngine()放到循环外，Engine Object 可以在多个 goroutine 间共享
+	// remove NewEngine() outside for loop, one Engine Object can be shared by goroutines
 	eng, err := dlp.NewEngine(caller)
 	if err != nil {
 		panic(err)
@@ -218,7 +218,7 @@ mac地址 06-06-06-aa-bb-cc
 	// 收件人：张真人  手机号码：18612341234 )
 	//
 	//	Total Results: 6
-	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"","byte_start":30,"byte_end":41,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"","byte_start":198,"byte_end":209,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":2,"text":"abcd@abcd.com","mask_text":"a***@********","result_type":"VALUE","key":"","byte_start":15,"byte_end":28,"info_type":"EMAIL","en_name":"EMAIL_address","cn_name":"电子邮箱","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":8,"text":"我家住在北京市海淀区北三环西路43号","mask_text":"我家住在北京市海淀区北三环西路**号","result_type":"VALUE","key":"","byte_start":80,"byte_end":130,"info_type":"ADDRESS","en_name":"address_cn","cn_name":"中文地址","group_name":"","level":"L1","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":9,"text":"张真人","mask_text":"张******","result_type":"VALUE","key":"收件人","byte_start":172,"byte_end":181,"info_type":"NAME","en_name":"name","cn_name":"人名","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":10,"text":"06-06-06-aa-bb-cc","mask_text":"06-06-06-**-**-**","result_type":"VALUE","key":"","byte_start":142,"byte_end":159,"info_type":"MACADDR","en_name":"MAC_address","cn_name":"MAC地址","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
+	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"","byte_start":30,"byte_end":41,"rune_start":20,"rune_end":31,"line":2,"column":1,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"","byte_start":198,"byte_end":209,"rune_start":104,"rune_end":115,"line":5,"column":15,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":2,"text":"abcd@abcd.com","mask_text":"a***@********","result_type":"VALUE","key":"","byte_start":15,"byte_end":28,"rune_start":5,"rune_end":18,"line":1,"column":6,"info_type":"EMAIL","en_name":"EMAIL_address","cn_name":"电子邮箱","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":8,"text":"我家住在北京市海淀区北三环西路43号","mask_text":"我家住在北京市海淀区北三环西路**号","result_type":"VALUE","key":"","byte_start":80,"byte_end":130,"rune_start":46,"rune_end":64,"line":3,"column":10,"info_type":"ADDRESS","en_name":"address_cn","cn_name":"中文地址","group_name":"","level":"L1","score":0.5,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":9,"text":"张真人","mask_text":"张******","result_type":"VALUE","key":"收件人","byte_start":172,"byte_end":181,"rune_start":94,"rune_end":97,"line":5,"column":5,"info_type":"NAME","en_name":"name","cn_name":"人名","group_name":"","level":"L4","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":10,"text":"06-06-06-aa-bb-cc","mask_text":"06-06-06-**-**-**","result_type":"VALUE","key":"","byte_start":142,"byte_end":159,"rune_start":72,"rune_end":89,"line":4,"column":7,"info_type":"MACADDR","en_name":"MAC_address","cn_name":"MAC地址","group_name":"","level":"L3","score":0.5,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
 	//
 	//	2. DeIdentify( inStr: 我的邮件是abcd@abcd.com,
 	// 18612341234是我的电话
@@ -237,22 +237,22 @@ mac地址 06-06-06-aa-bb-cc
 	//	4. DetectMap( inMap: map[k1:my phone is 18612341234 and 18612341234 nothing:nothing uid:10086] )
 	//
 	//	Total Results: 3
-	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":12,"byte_end":23,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":28,"byte_end":39,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"uid","byte_start":0,"byte_end":5,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
+	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":12,"byte_end":23,"rune_start":12,"rune_end":23,"line":1,"column":13,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":28,"byte_end":39,"rune_start":28,"rune_end":39,"line":1,"column":29,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"uid","byte_start":0,"byte_end":5,"rune_start":0,"rune_end":5,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
 	//	5. DeIdentifyMap( inMap: map[k1:my phone is 18612341234 and 18612341234 nothing:nothing uid:10086] )
 	//	outMap: map[k1:my phone is 186******34 and 186******34 nothing:nothing uid:1****]
 	//
 	//	Total Results: 3
-	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":12,"byte_end":23,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":28,"byte_end":39,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"uid","byte_start":0,"byte_end":5,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
+	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":12,"byte_end":23,"rune_start":12,"rune_end":23,"line":1,"column":13,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"k1","byte_start":28,"byte_end":39,"rune_start":28,"rune_end":39,"line":1,"column":29,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"uid","byte_start":0,"byte_end":5,"rune_start":0,"rune_end":5,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
 	//
 	//	6. DetectJSON( inJSON: {"objList":[{"uid":"10086"},{"uid":"[\"aaaa\",\"bbbb\"]"}]} )
 	//
 	//	Total Results: 3
-	// [{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"/objlist[0]/uid","byte_start":0,"byte_end":5,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"aaaa","mask_text":"a***","result_type":"KV","key":"/objlist[1]/uid[0]","byte_start":0,"byte_end":4,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"bbbb","mask_text":"b***","result_type":"KV","key":"/objlist[1]/uid[1]","byte_start":0,"byte_end":4,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
-	//	7. DeIdentifyJSONByResult( inJSON: {"objList":[{"uid":"10086"},{"uid":"[\"aaaa\",\"bbbb\"]"}]} , results: [{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"/objlist[0]/uid","byte_start":0,"byte_end":5,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"aaaa","mask_text":"a***","result_type":"KV","key":"/objlist[1]/uid[0]","byte_start":0,"byte_end":4,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"bbbb","mask_text":"b***","result_type":"KV","key":"/objlist[1]/uid[1]","byte_start":0,"byte_end":4,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}] )
+	// [{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"/objlist[0]/uid","byte_start":0,"byte_end":5,"rune_start":0,"rune_end":5,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"aaaa","mask_text":"a***","result_type":"KV","key":"/objlist[1]/uid[0]","byte_start":0,"byte_end":4,"rune_start":0,"rune_end":4,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"bbbb","mask_text":"b***","result_type":"KV","key":"/objlist[1]/uid[1]","byte_start":0,"byte_end":4,"rune_start":0,"rune_end":4,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
+	//	7. DeIdentifyJSONByResult( inJSON: {"objList":[{"uid":"10086"},{"uid":"[\"aaaa\",\"bbbb\"]"}]} , results: [{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"/objlist[0]/uid","byte_start":0,"byte_end":5,"rune_start":0,"rune_end":5,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"aaaa","mask_text":"a***","result_type":"KV","key":"/objlist[1]/uid[0]","byte_start":0,"byte_end":4,"rune_start":0,"rune_end":4,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"bbbb","mask_text":"b***","result_type":"KV","key":"/objlist[1]/uid[1]","byte_start":0,"byte_end":4,"rune_start":0,"rune_end":4,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}] )
 	//	outJSON: {"objList":[{"uid":"1****"},{"uid":"[\"a***\",\"b***\"]"}]}
 	//
 	//	Total Results: 3
-	// [{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"/objlist[0]/uid","byte_start":0,"byte_end":5,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"aaaa","mask_text":"a***","result_type":"KV","key":"/objlist[1]/uid[0]","byte_start":0,"byte_end":4,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"bbbb","mask_text":"b***","result_type":"KV","key":"/objlist[1]/uid[1]","byte_start":0,"byte_end":4,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
+	// [{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"KV","key":"/objlist[0]/uid","byte_start":0,"byte_end":5,"rune_start":0,"rune_end":5,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"aaaa","mask_text":"a***","result_type":"KV","key":"/objlist[1]/uid[0]","byte_start":0,"byte_end":4,"rune_start":0,"rune_end":4,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"bbbb","mask_text":"b***","result_type":"KV","key":"/objlist[1]/uid[1]","byte_start":0,"byte_end":4,"rune_start":0,"rune_end":4,"line":1,"column":1,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
 	//
 	//	8. Mask( inStr: abcd@abcd.com , EmailMaskRule01)
 	//	outStr: abcd@abcd.com
@@ -263,8 +263,8 @@ mac地址 06-06-06-aa-bb-cc
 	//	10. Detect( inStr: log info:[ uid:10086, phone:18612341234] )
 	//
 	//	Total Results: 3
-	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"","byte_start":28,"byte_end":39,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":35,"text":"18612341234","mask_text":"18*******34","result_type":"VALUE","key":"phone","byte_start":28,"byte_end":39,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"VALUE","key":"uid","byte_start":15,"byte_end":20,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
-	//	outStr: log info:[ uid:1****, phone:186******3418*******34]
+	// [{"rule_id":1,"text":"18612341234","mask_text":"186******34","result_type":"VALUE","key":"","byte_start":28,"byte_end":39,"rune_start":28,"rune_end":39,"line":1,"column":29,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.8,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":35,"text":"18612341234","mask_text":"18*******34","result_type":"VALUE","key":"phone","byte_start":28,"byte_end":39,"rune_start":28,"rune_end":39,"line":1,"column":29,"info_type":"PHONE","en_name":"telephone_number","cn_name":"电话号码","group_name":"","level":"L4","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}},{"rule_id":36,"text":"10086","mask_text":"1****","result_type":"VALUE","key":"uid","byte_start":15,"byte_end":20,"rune_start":15,"rune_end":20,"line":1,"column":16,"info_type":"UID","en_name":"userid","cn_name":"用户user_id","group_name":"","level":"L3","score":0.7,"ext_info":{"CnGroup":"用户数据","EnGroup":"user_data"}}]
+	//	outStr: log info:[ uid:1****, phone:18*******34]
 	//
 	//	11. MaskStruct( inPtr: , ExtInfo: {"Addr":"北京市海淀区北三环西路43号"})
 	//	outObj: , ExtInfo:{Addr:北京市海淀区北三环西路*****}
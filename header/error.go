@@ -2,6 +2,7 @@ package header
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
@@ -30,6 +31,10 @@ var (
 	ErrMaskFailed           = errors.New("[DLP] Mask Failed, input is returned")
 	ErrMaskTagNotSupport    = errors.New("[DLP] Mask() dose not support with MaskType: Tag, which is used in DeIdentify()")
 	ErrMaskNameConflict     = errors.New("[DLP] MaskName conflicts with MaskRules.RuleName")
+	ErrVerifierNameConflict = errors.New("[DLP] Verifier name conflicts with a built-in VAlgo or a registered one")
+	ErrVerifierInvalid      = errors.New("[DLP] Verifier name is empty or verifier func is nil")
+	ErrDetectorConflict     = errors.New("[DLP] RuleID of detector conflicts with Rules or a registered detector")
+	ErrDetectorInvalid      = errors.New("[DLP] Detector is nil")
 	ErrMaskRuleNotfound     = errors.New("[DLP] Mask Rule is not Found")
 	ErrDataMarshal          = errors.New("[DLP] Data marshal error")
 	ErrSendRequest          = errors.New("[DLP] SendRequest error")
@@ -37,3 +42,20 @@ var (
 	ErrMaskStructOutput     = errors.New("[DLP] Internal Error of MaskStruct, output is nil")
 	ErrOnlyForLog           = errors.New("[DLP] NewLogProcessor() has been called. engine can be only used for log")
 )
+
+// LimitError is returned when an input exceeds a limitation of Engine, errors.Is(err, ErrMaxInputLimit) is true for it
+type LimitError struct {
+	Limit  string // name of the limitation, such as MaxInput, MaxItem
+	Max    int    // value of the limitation
+	Actual int    // actual size of the input
+}
+
+// Error implements error
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s, %s: %d, actual: %d", ErrMaxInputLimit.Error(), e.Limit, e.Max, e.Actual)
+}
+
+// Unwrap returns ErrMaxInputLimit
+func (e *LimitError) Unwrap() error {
+	return ErrMaxInputLimit
+}
@@ -2,7 +2,10 @@
 package header
 
 import (
+	"context"
+	"io"
 	"strings"
+	"time"
 )
 
 // DetectResult Data Structure. Two kinds of result
@@ -18,12 +21,19 @@ type DetectResult struct {
 	// In ResultType: KV, DetectResult.Text will be inputMap[DetectResult.Key][ByteStart:ByteEnd]
 	ByteStart int `json:"byte_start"`
 	ByteEnd   int `json:"byte_end"`
+	// RuneStart and RuneEnd are offsets in runes, the same as ByteStart and ByteEnd
+	RuneStart int `json:"rune_start"`
+	RuneEnd   int `json:"rune_end"`
+	// Line and Column are 1-based position of ByteStart, Column is counted in runes
+	Line   int `json:"line"`
+	Column int `json:"column"`
 	// fields are defined in conf file
 	InfoType  string            `json:"info_type"`
 	EnName    string            `json:"en_name"`
 	CnName    string            `json:"cn_name"`
 	GroupName string            `json:"group_name"`
 	Level     string            `json:"level"`
+	Score     float64           `json:"score"` // confidence of the result in [0, 1], see Score of rules
 	ExtInfo   map[string]string `json:"ext_info,omitempty"`
 }
 
@@ -86,6 +96,10 @@ type EngineAPI interface {
 	// 获取版本号
 	GetVersion() string
 
+	// Stats returns a snapshot of hit counts of rules, call counts, bytes scanned and latency of APIs
+	// 返回规则命中次数、接口调用次数、扫描字节数和耗时分布的统计快照
+	Stats() *EngineStats
+
 	// DisableAllRules will disable all rules, only used for benchmark baseline
 	// 业务禁止使用
 	DisableAllRules() error
@@ -101,6 +115,25 @@ type EngineConfAPI interface {
 	// 传入filePath 进行配置
 	ApplyConfigFile(filePath string) error
 
+	// ApplyConfigLayers applies the default config overlaid by private layers in order,
+	// a layer may override fields of default rules by RuleID and add private rules with RuleID >= 10000
+	// 传入私有配置 string 列表，依次叠加在默认配置上进行配置
+	ApplyConfigLayers(layers ...string) error
+
+	// ApplyConfigLayerFiles applies the default config overlaid by private layer files in order
+	// 传入私有配置文件列表，依次叠加在默认配置上进行配置
+	ApplyConfigLayerFiles(filePaths ...string) error
+
+	// GetEffectiveConf returns the config in use in YAML format, such as the merged config of ApplyConfigLayers
+	// 返回当前生效的配置
+	GetEffectiveConf() (string, error)
+
+	// WatchConfigFile applies config file, then checks it every interval and reloads it after it is changed.
+	// A reload which fails keeps the previous rules, onReload is called with the result of every reload.
+	// Call stop or Close() to stop watching.
+	// 传入filePath 进行配置，并定期检查文件变更后重新加载，加载失败时保留原有规则
+	WatchConfigFile(filePath string, interval time.Duration, onReload func(err error)) (stop func(), err error)
+
 	// ShowDlpConf will print config file
 	// 打印配置文件
 	ShowDlpConf() error
@@ -118,15 +151,45 @@ type EngineConfAPI interface {
 type EngineDetectAPI interface {
 	// Detect string
 	// 对string进行敏感信息识别
-	Detect(inputText string) ([]*DetectResult, error)
+	Detect(inputText string, opts ...DetectOption) ([]*DetectResult, error)
 
 	// DetectMap detects KV map
 	// 对map[string]string进行敏感信息识别
-	DetectMap(inputMap map[string]string) ([]*DetectResult, error)
+	DetectMap(inputMap map[string]string, opts ...DetectOption) ([]*DetectResult, error)
 
 	// DetectJSON detects json string
 	// 对json string 进行敏感信息识别
-	DetectJSON(jsonText string) ([]*DetectResult, error)
+	DetectJSON(jsonText string, opts ...DetectOption) ([]*DetectResult, error)
+
+	// DetectStream detects sensitive information for a reader without input size limitation,
+	// onResult is called for every result in order, a non-nil error returned by onResult stops detection
+	// 对io.Reader进行流式敏感信息识别，不限制输入大小
+	DetectStream(r io.Reader, onResult func(res *DetectResult) error, opts ...DetectOption) error
+
+	// DetectContext is Detect with ctx, detection stops once ctx is done,
+	// results found so far are returned with ctx.Err()
+	// 带 context 的 Detect，ctx 结束后停止识别，返回已识别的结果和 ctx.Err()
+	DetectContext(ctx context.Context, inputText string, opts ...DetectOption) ([]*DetectResult, error)
+
+	// DetectMapContext is DetectMap with ctx, detection stops once ctx is done,
+	// results found so far are returned with ctx.Err()
+	// 带 context 的 DetectMap，ctx 结束后停止识别，返回已识别的结果和 ctx.Err()
+	DetectMapContext(ctx context.Context, inputMap map[string]string, opts ...DetectOption) ([]*DetectResult, error)
+
+	// DetectJSONContext is DetectJSON with ctx, detection stops once ctx is done,
+	// results found so far are returned with ctx.Err()
+	// 带 context 的 DetectJSON，ctx 结束后停止识别，返回已识别的结果和 ctx.Err()
+	DetectJSONContext(ctx context.Context, jsonText string, opts ...DetectOption) ([]*DetectResult, error)
+
+	// DetectStreamContext is DetectStream with ctx, detection stops once ctx is done and ctx.Err() is returned
+	// 带 context 的 DetectStream，ctx 结束后停止识别并返回 ctx.Err()
+	DetectStreamContext(ctx context.Context, r io.Reader, onResult func(res *DetectResult) error,
+		opts ...DetectOption) error
+
+	// RegisterVerifier registers a verification algorithm which can be used by name in Verify.VAlgo of config,
+	// it must be called before ApplyConfig* which uses it
+	// 注册自定义校验函数，可以在配置的 Verify.VAlgo 中按名称使用，需要在 ApplyConfig* 之前调用
+	RegisterVerifier(name string, fn func(res *DetectResult, context []byte) bool) error
 }
 
 // EngineDeIdentifyAPI is a collection of dlp de identify APIs
@@ -138,15 +201,40 @@ type EngineDeIdentifyAPI interface {
 
 	// DeIdentify detects string firstly, then return masked string and results
 	// 对string先识别，然后按规则进行打码
-	DeIdentify(inputText string) (string, []*DetectResult, error)
+	DeIdentify(inputText string, opts ...DetectOption) (string, []*DetectResult, error)
 
 	// DeIdentifyMap detects KV map firstly,then return masked map
 	// 对map[string]string先识别，然后按规则进行打码
-	DeIdentifyMap(inputMap map[string]string) (map[string]string, []*DetectResult, error)
+	DeIdentifyMap(inputMap map[string]string, opts ...DetectOption) (map[string]string, []*DetectResult, error)
 
 	// DeIdentifyJSON detects JSON firstly, then return masked json object in string format and results
 	// 对jsonText先识别，然后按规则进行打码，返回打码后的JSON string
-	DeIdentifyJSON(jsonText string) (string, []*DetectResult, error)
+	DeIdentifyJSON(jsonText string, opts ...DetectOption) (string, []*DetectResult, error)
+
+	// DeIdentifyStream detects input from r, then writes masked text into w, input size is not limited
+	// 对io.Reader进行流式识别，然后将打码后的文本写入io.Writer，不限制输入大小
+	DeIdentifyStream(r io.Reader, w io.Writer, opts ...DetectOption) error
+
+	// DeIdentifyContext is DeIdentify with ctx, detection stops once ctx is done,
+	// text masked by results found so far is returned with ctx.Err()
+	// 带 context 的 DeIdentify，ctx 结束后停止识别，返回按已识别结果打码的文本和 ctx.Err()
+	DeIdentifyContext(ctx context.Context, inputText string, opts ...DetectOption) (string, []*DetectResult, error)
+
+	// DeIdentifyMapContext is DeIdentifyMap with ctx, detection stops once ctx is done,
+	// map masked by results found so far is returned with ctx.Err()
+	// 带 context 的 DeIdentifyMap，ctx 结束后停止识别，返回按已识别结果打码的map和 ctx.Err()
+	DeIdentifyMapContext(ctx context.Context, inputMap map[string]string,
+		opts ...DetectOption) (map[string]string, []*DetectResult, error)
+
+	// DeIdentifyJSONContext is DeIdentifyJSON with ctx, detection stops once ctx is done,
+	// json masked by results found so far is returned with ctx.Err()
+	// 带 context 的 DeIdentifyJSON，ctx 结束后停止识别，
+	// 返回按已识别结果打码的JSON string和 ctx.Err()
+	DeIdentifyJSONContext(ctx context.Context, jsonText string, opts ...DetectOption) (string, []*DetectResult, error)
+
+	// DeIdentifyStreamContext is DeIdentifyStream with ctx, it stops once ctx is done and ctx.Err() is returned
+	// 带 context 的 DeIdentifyStream，ctx 结束后停止处理并返回 ctx.Err()
+	DeIdentifyStreamContext(ctx context.Context, r io.Reader, w io.Writer, opts ...DetectOption) error
 }
 
 // EngineProcessorAPI is a collection of dlp processor APIs
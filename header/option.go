@@ -0,0 +1,87 @@
+package header
+
+// DetectOptions stores per-call options of detect and de identify APIs, set by DetectOption
+type DetectOptions struct {
+	RuleIDs   []int32           // only rules in RuleIDs are used, empty means all rules
+	InfoTypes []string          // only rules of InfoTypes are used, empty means all rules
+	MinLevel  string            // only rules with Level >= MinLevel are used, such as L3, empty means all rules
+	SkipMask  bool              // MaskText will be same as Text, DeIdentify* APIs return the input
+	MaskRules map[string]string // InfoType -> MaskRule name, overrides Mask of rules
+	MinScore  float64           // results with Score < MinScore are dropped, in addition to MinScore of rules
+	// Deobfuscate detects text again after spaced and spelled-out digits and "at/dot" emails are canonicalized
+	Deobfuscate bool
+}
+
+// DetectOption sets DetectOptions for a call of detect and de identify APIs
+type DetectOption func(o *DetectOptions)
+
+// NewDetectOptions applies opts to an empty DetectOptions
+func NewDetectOptions(opts ...DetectOption) *DetectOptions {
+	o := new(DetectOptions)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// WithRuleIDs restricts the call to rules in ruleIDs
+// 只使用指定RuleID的规则
+func WithRuleIDs(ruleIDs ...int32) DetectOption {
+	return func(o *DetectOptions) {
+		o.RuleIDs = append(o.RuleIDs, ruleIDs...)
+	}
+}
+
+// WithInfoTypes restricts the call to rules of infoTypes
+// 只使用指定InfoType的规则
+func WithInfoTypes(infoTypes ...string) DetectOption {
+	return func(o *DetectOptions) {
+		o.InfoTypes = append(o.InfoTypes, infoTypes...)
+	}
+}
+
+// WithMinLevel restricts the call to rules whose Level is not lower than level, level is one of L1 ~ L4
+// 只使用敏感级别不低于level的规则
+func WithMinLevel(level string) DetectOption {
+	return func(o *DetectOptions) {
+		o.MinLevel = level
+	}
+}
+
+// WithMinScore drops results whose Score is lower than minScore
+// 丢弃置信度低于minScore的结果
+func WithMinScore(minScore float64) DetectOption {
+	return func(o *DetectOptions) {
+		o.MinScore = minScore
+	}
+}
+
+// WithDeobfuscation detects text again after spaced and spelled-out digits and "at/dot" emails are canonicalized,
+// such as "1 8 6-1234-1234", "幺八六幺二三四幺二三四" and "abc at example dot com", the same as Global.Deobfuscate
+// 识别去除混淆后的文本，例如带分隔符或用英文、中文写出的数字，以及 "at/dot" 写法的邮箱
+func WithDeobfuscation() DetectOption {
+	return func(o *DetectOptions) {
+		o.Deobfuscate = true
+	}
+}
+
+// WithoutMask skips masking, MaskText of results will be same as Text
+// 不打码，MaskText与Text相同
+func WithoutMask() DetectOption {
+	return func(o *DetectOptions) {
+		o.SkipMask = true
+	}
+}
+
+// WithMaskRule masks results of infoType by MaskRule maskRuleName instead of Mask of rules
+// 对指定InfoType的结果使用maskRuleName打码
+func WithMaskRule(infoType string, maskRuleName string) DetectOption {
+	return func(o *DetectOptions) {
+		if o.MaskRules == nil {
+			o.MaskRules = make(map[string]string)
+		}
+		o.MaskRules[infoType] = maskRuleName
+	}
+}
@@ -0,0 +1,31 @@
+package header
+
+// EngineStats is a snapshot of the statistics of an Engine, returned by Stats()
+type EngineStats struct {
+	Rules        map[int32]RuleStats `json:"rules"`         // RuleID -> statistics of the rule
+	APIs         map[string]APIStats `json:"apis"`          // API name -> statistics of the API, such as Detect
+	BytesScanned int64               `json:"bytes_scanned"` // total bytes of input of all APIs
+}
+
+// RuleStats is the statistics of a rule
+type RuleStats struct {
+	Hits        int64 `json:"hits"`         // results returned to caller
+	Filtered    int64 `json:"filtered"`     // results removed by Filter section of the rule
+	VerifiedOut int64 `json:"verified_out"` // results removed by Verify section or MinScore of the rule
+}
+
+// APIStats is the statistics of an API, Context APIs are counted with the ones without Context
+type APIStats struct {
+	Calls        int64     `json:"calls"`
+	Errors       int64     `json:"errors"`        // calls which return a non-nil error
+	BytesScanned int64     `json:"bytes_scanned"` // bytes of input, keys and values are counted for map APIs
+	Latency      Histogram `json:"latency"`
+}
+
+// Histogram is a latency histogram in seconds
+type Histogram struct {
+	Bounds []float64 `json:"bounds"` // upper bounds of buckets in seconds, in increasing order
+	Counts []int64   `json:"counts"` // Counts[i] is the number of observations <= Bounds[i]
+	Count  int64     `json:"count"`  // number of all observations
+	Sum    float64   `json:"sum"`    // sum of all observations in seconds
+}
@@ -0,0 +1,306 @@
+// Package keypath implements path patterns of keys, such as keys of DetectMap and paths of DetectJSON
+package keypath
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Pattern is a compiled path pattern, it is matched case-insensitively by segments.
+//
+// Glob syntax, segments are separated by '/':
+//   - "/user/name" matches the path exactly, a pattern without leading '/' matches at any depth
+//   - "*" as a segment matches one segment, in a segment it matches any characters, '?' matches one
+//   - "**" matches zero or more segments, such as "/user/**"
+//   - "[n]" matches the array index n, "[*]" matches any index, such as "/users[*]/phone"
+//
+// JSONPath subset, the pattern starts with '$': "$.user.name", "$..phone", "$.users[*].phone", "$['user']".
+type Pattern struct {
+	raw  string
+	segs []string
+}
+
+// Set is a list of patterns, it matches a path if one of the patterns matches
+type Set []*Pattern
+
+// anySegments is the segment matching zero or more segments
+const anySegments = "**"
+
+// Compile compiles a glob or JSONPath pattern
+func Compile(pattern string) (*Pattern, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("path pattern is empty")
+	}
+	var segs []string
+	var err error
+	if pattern[0] == '$' {
+		segs, err = compileJSONPath(pattern)
+	} else {
+		segs, err = compileGlob(pattern)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("path pattern %q, %s", pattern, err.Error())
+	}
+	return &Pattern{raw: pattern, segs: segs}, nil
+}
+
+// CompileSet compiles all of patterns, nil is returned for empty patterns
+func CompileSet(patterns []string) (Set, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	set := make(Set, 0, len(patterns))
+	for _, pattern := range patterns {
+		p, err := Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, p)
+	}
+	return set, nil
+}
+
+// String returns the pattern in lower case
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Match checks whether path matches p, path is a key of DetectMap or a path of DetectJSON, such as
+// "phone", "/user/phone" and "/users[0]/phone"
+func (p *Pattern) Match(path string) bool {
+	return matchSegments(p.segs, Split(path))
+}
+
+// Match checks whether path matches one of patterns in set
+func (set Set) Match(path string) bool {
+	if len(set) == 0 {
+		return false
+	}
+	segs := Split(path)
+	for _, p := range set {
+		if matchSegments(p.segs, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// Split splits path into lower case segments, an array index is a segment, "/users[0]/phone" is
+// split into ["users", "[0]", "phone"]
+func Split(path string) []string {
+	path = strings.ToLower(path)
+	segs := make([]string, 0, strings.Count(path, "/")+1)
+	for _, part := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		segs = appendIndexSegments(segs, part)
+	}
+	return segs
+}
+
+// Tokens splits a key into lower case words by naming styles, words are separated by characters which are
+// not letters or digits, by case changes of camelCase and by changes between Han and other letters,
+// such as "user.mobilePhone", "USER_MOBILE_PHONE" and "user-mobile-phone" are all [user mobile phone]
+func Tokens(key string) []string {
+	runes := []rune(key)
+	tokens := make([]string, 0, 4)
+	st := -1 // start of the current token
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if st != -1 {
+				tokens = append(tokens, strings.ToLower(string(runes[st:i])))
+				st = -1
+			}
+			continue
+		}
+		if st != -1 && isTokenStart(runes, i) {
+			tokens = append(tokens, strings.ToLower(string(runes[st:i])))
+			st = i
+		}
+		if st == -1 {
+			st = i
+		}
+	}
+	if st != -1 {
+		tokens = append(tokens, strings.ToLower(string(runes[st:])))
+	}
+	return tokens
+}
+
+// private func
+
+// isTokenStart checks whether runes[i] starts a new token in the middle of letters and digits,
+// "mobilePhone" starts at 'P', "HTTPServer" starts at 'S', "用户id" starts at 'i'
+func isTokenStart(runes []rune, i int) bool {
+	prev, cur := runes[i-1], runes[i]
+	if isHan(prev) != isHan(cur) {
+		return true
+	}
+	if !unicode.IsUpper(cur) {
+		return false
+	}
+	if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+		return true
+	}
+	// an upper case letter after upper case letters starts a word only if it is followed by a lower case one
+	return unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+}
+
+// isHan checks whether r is a Chinese character
+func isHan(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// appendIndexSegments appends part into segs, "name[0][1]" is appended as "name", "[0]", "[1]"
+func appendIndexSegments(segs []string, part string) []string {
+	pos := strings.IndexByte(part, '[')
+	if pos == -1 || !strings.HasSuffix(part, "]") {
+		return append(segs, part)
+	}
+	if pos > 0 {
+		segs = append(segs, part[:pos])
+	}
+	for rest := part[pos:]; len(rest) != 0; {
+		ed := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || ed == -1 { // not an index, such as "a[0]b]", keep the rest as is
+			return append(segs, rest)
+		}
+		segs = append(segs, rest[:ed+1])
+		rest = rest[ed+1:]
+	}
+	return segs
+}
+
+// compileGlob compiles a glob pattern into segments
+func compileGlob(pattern string) ([]string, error) {
+	segs := make([]string, 0, strings.Count(pattern, "/")+2)
+	if pattern[0] != '/' { // relative pattern matches at any depth
+		segs = append(segs, anySegments)
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(pattern, "/"), "/") {
+		if len(part) == 0 {
+			return nil, fmt.Errorf("empty segment")
+		}
+		if strings.Contains(part, anySegments) && part != anySegments {
+			return nil, fmt.Errorf("%q must be a whole segment", anySegments)
+		}
+		n := len(segs)
+		segs = appendIndexSegments(segs, part)
+		for _, seg := range segs[n:] {
+			if err := checkSegment(seg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return segs, nil
+}
+
+// compileJSONPath compiles a JSONPath pattern into segments
+func compileJSONPath(pattern string) ([]string, error) {
+	segs := make([]string, 0, strings.Count(pattern, ".")+1)
+	for rest := pattern[1:]; len(rest) != 0; {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			segs = append(segs, anySegments)
+			rest = rest[1:] // the name after ".." is read as ".name"
+		case rest[0] == '.':
+			ed := strings.IndexAny(rest[1:], ".[")
+			if ed == -1 {
+				ed = len(rest) - 1
+			}
+			if ed == 0 {
+				return nil, fmt.Errorf("empty name")
+			}
+			segs = append(segs, rest[1:ed+1])
+			rest = rest[ed+1:]
+		case rest[0] == '[':
+			ed := strings.IndexByte(rest, ']')
+			if ed == -1 {
+				return nil, fmt.Errorf("']' is missing")
+			}
+			inner := rest[1:ed]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segs = append(segs, inner[1:len(inner)-1]) // ['name']
+			} else {
+				segs = append(segs, rest[:ed+1])
+			}
+			rest = rest[ed+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+		if err := checkSegment(segs[len(segs)-1]); err != nil {
+			return nil, err
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("no segment after '$'")
+	}
+	return segs, nil
+}
+
+// checkSegment checks an index segment, which must be [n] or [*]
+func checkSegment(seg string) error {
+	if !strings.HasPrefix(seg, "[") {
+		return nil
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(seg, "["), "]")
+	if inner == "*" {
+		return nil
+	}
+	if len(inner) == 0 || strings.Trim(inner, "0123456789") != "" {
+		return fmt.Errorf("index %q need [n] or [*]", seg)
+	}
+	return nil
+}
+
+// matchSegments matches segments of a path by segments of a pattern
+func matchSegments(pattern []string, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == anySegments {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 || !matchSegment(pattern[0], path[0]) {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// matchSegment matches a segment, "*" in a name matches any characters and '?' matches one character
+func matchSegment(pattern string, seg string) bool {
+	if pattern == "*" || (pattern == "[*]" && strings.HasPrefix(seg, "[")) {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "*?") || strings.HasPrefix(pattern, "[") {
+		return pattern == seg
+	}
+	pr, sr := []rune(pattern), []rune(seg)
+	// star is the last '*' in pattern and next is the position in seg to retry from
+	star, next := -1, 0
+	p, s := 0, 0
+	for s < len(sr) {
+		switch {
+		case p < len(pr) && (pr[p] == '?' || pr[p] == sr[s]):
+			p++
+			s++
+		case p < len(pr) && pr[p] == '*':
+			star, next = p, s
+			p++
+		case star != -1:
+			next++
+			p, s = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pr) && pr[p] == '*' {
+		p++
+	}
+	return p == len(pr)
+}
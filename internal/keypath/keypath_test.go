@@ -0,0 +1,74 @@
+package keypath_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/laojianzi/godlp/internal/keypath"
+)
+
+func TestPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/user/phone", "/user/phone", true},
+		{"/user/phone", "/User/Phone", true},
+		{"/user/phone", "/user/phone/ext", false},
+		{"phone", "/user/phone", true},
+		{"phone", "phone", true},
+		{"/user/**", "/user/info/phone", true},
+		{"/user/**", "/users/phone", false},
+		{"/user/*", "/user/info/phone", false},
+		{"/*/phone", "/user/phone", true},
+		{"/users[*]/phone", "/users[3]/phone", true},
+		{"/users[1]/phone", "/users[3]/phone", false},
+		{"/users/*/phone", "/users[3]/phone", true},
+		{"*_id", "/metadata/trace_id", true},
+		{"/[*]/phone", "/[0]/phone", true},
+		{"/user/ph?ne", "/user/phone", true},
+		{"$.user.phone", "/user/phone", true},
+		{"$..phone", "/a/b[2]/phone", true},
+		{"$.users[*].phone", "/users[0]/phone", true},
+		{"$['user'].*", "/user/phone", true},
+		{"$.user.phone", "/user/name", false},
+	}
+	for _, tt := range tests {
+		p, err := keypath.Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q) got err = %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.path); got != tt.want {
+			t.Errorf("Compile(%q).Match(%q) got = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "/user//phone", "/user**", "/users[x]", "$.user[0", "$", "$user"} {
+		if _, err := keypath.Compile(pattern); err == nil {
+			t.Errorf("Compile(%q) got err = nil, want an error", pattern)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"phone", "phone"},
+		{"user.mobilePhone", "user mobile phone"},
+		{"USER_MOBILE_PHONE", "user mobile phone"},
+		{"user-mobile-phone", "user mobile phone"},
+		{"HTTPServerURL", "http server url"},
+		{"contact_phone2", "contact phone2"},
+		{"用户phone", "用户 phone"},
+		{"/users[0]/phoneNo", "users 0 phone no"},
+		{"--", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(keypath.Tokens(tt.key), " "); got != tt.want {
+			t.Errorf("Tokens(%q) got = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
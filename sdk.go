@@ -2,11 +2,14 @@
 package dlp
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
-	"reflect"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"gopkg.in/yaml.v2"
@@ -34,35 +37,57 @@ const (
 
 // const var for default values
 const (
-	DefMaxInput      = 1024 * 1024                      // 1MB, the getMax input string length
-	DefLimitError    = "<--[DLP] Log Limit Exceeded-->" // append to log if limit is exceeded
-	DefMaxLogItem    = 16                               // getMax input items for log
-	DefResultSize    = 4                                // default results size for array allocation
-	DefLineBlockSize = 1024                             // default line block
-	DefCutter        = " /\r\n\\[](){}:=\"',"           // default cutter for finding KV object in string
-	DefMaxItem       = 1024 * 4                         // getMax input items for MAP API
-	DefMaxCallDeep   = 5                                // getMax call depth for MaskStruct
+	DefMaxInput        = 1024 * 1024                      // 1MB, the getMax input string length
+	DefLimitError      = "<--[DLP] Log Limit Exceeded-->" // append to log if limit is exceeded
+	DefMaxLogItem      = 16                               // getMax input items for log
+	DefResultSize      = 4                                // default results size for array allocation
+	DefLineBlockSize   = 1024                             // default line block
+	DefCutter          = " /\r\n\\[](){}:=\"',"           // default cutter for finding KV object in string
+	DefMaxItem         = 1024 * 4                         // getMax input items for MAP API
+	DefMaxCallDeep     = 5                                // getMax call depth for MaskStruct
+	DefWatchInterval   = 5 * time.Second                  // default interval for WatchConfigFile to check the file
+	DefStreamChunkSize = 64 * 1024                        // chunk size of a long line for stream APIs
+	DefStreamOverlap   = 1024                             // bytes after a chunk which are detected together with it
+	DefMultiLineWindow = 16 * 1024                        // bytes of lines after a chunk for MultiLine rules in streams
 )
 
+// default values of Limits, NewEngine and NewEngineWithOptions copy them into the engine, so a change of them
+// only affects engines created after it, change them per engine in conf or NewEngineWithOptions
 var (
-	DefMaxLogInput    int32 = 1024 // default 1KB, the getMax input length for log, change it in conf
-	DefMaxRegexRuleID int32 = 0    // default 0, no regex rule will be used for log default, change it in conf
+	DefMaxLogInput    int32 = 1024 // default 1KB, the getMax input length for log
+	DefMaxRegexRuleID int32 = 0    // default 0, no regex rule will be used for log default
 )
 
-// Engine Object implements all DLP API functions
+// Engine Object implements all DLP API functions, it is safe for concurrent use by multiple goroutines
 type Engine struct {
-	Version      string
-	callerID     string
-	endPoint     string // nolint: unused
-	accessKey    string // nolint: unused
-	secretKey    string // nolint: unused
-	isLegal      bool   // true: auth is ok, false: auth failed
-	isClosed     bool   // true: Close() has been called
-	isForLog     bool   // true: NewLogProcessor() has been called, will not do other API
-	isConfigured bool   // true: ApplyConfig* API has been called, false: not been called
-	confObj      *conf.DlpConf
-	detectorMap  map[int32]detector.API
-	maskerMap    map[string]mask.API
+	Version   string
+	callerID  string
+	endPoint  string // nolint: unused
+	accessKey string // nolint: unused
+	secretKey string // nolint: unused
+	isLegal   bool   // true: auth is ok, false: auth failed
+	isClosed  int32  // 1: Close() has been called
+	isForLog  int32  // 1: NewLogProcessor() has been called, will not do other API
+	// rules stores *ruleSet, nil means ApplyConfig* API has not been called
+	rules atomic.Value
+	// mu serializes writers which swap rules, readers never lock
+	mu sync.Mutex
+	// diyMaskerMap keeps maskers of RegisterMasker, they survive ApplyConfig*, guarded by mu
+	diyMaskerMap map[string]mask.API
+	// diyVerifierMap keeps verifiers of RegisterVerifier, it is replaced instead of modified, guarded by mu
+	diyVerifierMap map[string]detector.VerifyFunc
+	// diyDetectorMap keeps detectors of RegisterDetector and WithDetectors, they survive ApplyConfig*, guarded by mu
+	diyDetectorMap map[int32]detector.API
+	// watchers are running WatchConfigFile calls, guarded by mu
+	watchers map[*configWatcher]struct{}
+	// limits are set by NewEngineWithOptions, zero values are taken from Global of config or defLimits
+	limits Limits
+	// defLimits are DefLimits() when the engine is created
+	defLimits Limits
+	// stats collects statistics of APIs and rules, it survives ApplyConfig*
+	stats *engineStats
+	// latencyBounds are set by WithLatencyBounds, they are copied into stats by NewEngineWithOptions
+	latencyBounds []float64
 }
 
 // NewEngine creates an Engine Object
@@ -75,32 +100,56 @@ type Engine struct {
 //
 //	Comment: 不要放在循环中调用
 func NewEngine(callerID string) (header.EngineAPI, error) {
+	return NewEngineWithOptions(callerID)
+}
+
+// NewEngineWithOptions creates an Engine Object with options, such as WithLimits
+//
+//	Parameters:
+//		callerID: caller ID at the dlp management system.
+//		opts: engine options, limits of opts override the ones in Global of config
+//
+//	Return:
+//		EngineAPI Object
+//
+//	Comment: 不要放在循环中调用
+func NewEngineWithOptions(callerID string, opts ...EngineOption) (header.EngineAPI, error) {
 	defer recoveryImplStatic()
 	eng := new(Engine)
 	eng.Version = Version
 	eng.callerID = callerID
-	eng.detectorMap = make(map[int32]detector.API)
-	eng.maskerMap = make(map[string]mask.API)
+	eng.diyMaskerMap = make(map[string]mask.API)
+	eng.diyDetectorMap = make(map[int32]detector.API)
+	eng.defLimits = DefLimits()
+	for _, opt := range opts {
+		opt(eng)
+	}
+	eng.stats = newEngineStats(eng.latencyBounds)
 	return eng, nil
 }
 
-// Close release inner object, such as detector and masker
+// Close stops config watchers and drops the rules, registered maskers, verifiers and detectors,
+// APIs return header.ErrProcessAfterClose after it. Detectors of the dropped rule set are not closed,
+// because in-flight calls may still use them, they are released by GC once those calls return.
+// 关闭 Engine，停止配置文件监听并丢弃规则，之后调用接口返回 header.ErrProcessAfterClose
 func (I *Engine) Close() {
 	defer I.recoveryImpl()
-	for k, v := range I.detectorMap {
-		if v != nil {
-			v.Close()
-			I.detectorMap[k] = nil
-		}
+	I.mu.Lock()
+	atomic.StoreInt32(&I.isClosed, 1)
+	watchers := I.watchers
+	I.watchers = nil
+	if I.currentRuleSet() != nil {
+		I.storeRuleSet(newRuleSet(nil, I.defLimits, I.limits))
 	}
-	for k, v := range I.maskerMap {
-		if v != nil {
-			I.maskerMap[k] = nil
-		}
+	I.diyMaskerMap = make(map[string]mask.API)
+	I.diyVerifierMap = nil
+	I.diyDetectorMap = make(map[int32]detector.API)
+	I.mu.Unlock()
+
+	// stopped without I.mu, a running reload needs it to finish, it fails as the engine is closed
+	for w := range watchers {
+		w.stop()
 	}
-	I.detectorMap = nil
-	I.confObj = nil
-	I.isClosed = true
 }
 
 // ShowResults print results in console
@@ -132,21 +181,27 @@ func (I *Engine) GetVersion() string {
 func (I *Engine) NewLogProcessor() header.Processor {
 	defer I.recoveryImpl()
 
-	I.isForLog = true
+	atomic.StoreInt32(&I.isForLog, 1)
 	_ = I.selectRulesForLog()
 	return func(rawLog string, kvs ...interface{}) (string, []interface{}, bool) {
 		// do not call log API in this func
 		defer I.recoveryImpl()
+		var results []*header.DetectResult // results of rawLog and kvs, they are counted as hits of rules
+		defer I.stats.observe(APILogProcessor, time.Now(), len(rawLog), &results, nil)
+		rs := I.currentRuleSet()
+		if rs == nil || I.hasClosed() {
+			return rawLog, kvs, false
+		}
 		// do not call report at here, because this func will call DeIdentify()
 		// Do not use logs function inside this function
 		newLog := rawLog
 		logCut := false
-		if int32(len(newLog)) >= DefMaxLogInput {
+		if len(newLog) >= rs.limits.MaxLogInput {
 			// cut for long log
-			newLog = newLog[:DefMaxLogInput]
+			newLog = newLog[:rs.limits.MaxLogInput]
 			logCut = true
 		}
-		newLog, _, _ = I.deIdentifyImpl(newLog)
+		newLog, results, _ = I.deIdentifyImpl(context.Background(), rs, newLog)
 		if logCut {
 			newLog += DefLimitError
 		}
@@ -157,9 +212,9 @@ func (I *Engine) NewLogProcessor() header.Processor {
 			sz--
 		}
 		kvCut := false
-		if sz >= DefMaxLogItem {
+		if sz >= rs.limits.MaxLogItem {
 			// cut for too many items
-			sz = DefMaxLogItem
+			sz = rs.limits.MaxLogItem
 			kvCut = true
 		}
 		retKvs := make([]interface{}, 0, sz)
@@ -170,9 +225,12 @@ func (I *Engine) NewLogProcessor() header.Processor {
 				valStr := I.interfaceToStr(kvs[i+1])
 				inMap[keyStr] = valStr
 			}
-			outMap, _, _ := I.deIdentifyMapImpl(inMap)
+			outMap, mapResults, _ := I.deIdentifyMapImpl(context.Background(), rs, inMap)
+			results = append(results, mapResults...)
 			for k, v := range outMap {
-				v, _, _ = I.deIdentifyImpl(v)
+				var valueResults []*header.DetectResult
+				v, valueResults, _ = I.deIdentifyImpl(context.Background(), rs, v)
+				results = append(results, valueResults...)
 				retKvs = append(retKvs, k, v)
 			}
 		}
@@ -193,8 +251,12 @@ func (I *Engine) NewEmptyLogProcessor() header.Processor {
 
 // ShowDlpConf print conf on console
 func (I *Engine) ShowDlpConf() error {
+	rs := I.currentRuleSet()
+	if rs == nil || rs.confObj == nil {
+		return header.ErrHasNotConfigured
+	}
 	// copy obj
-	confObj := *I.confObj
+	confObj := *rs.confObj
 	out, err := yaml.Marshal(confObj)
 	if err == nil {
 		logger.Debugf("====godlp conf start====\n")
@@ -214,8 +276,13 @@ func (I *Engine) GetDefaultConf() string {
 
 // DisableAllRules will disable all rules of engine
 func (I *Engine) DisableAllRules() error {
-	for i := range I.detectorMap {
-		I.detectorMap[i] = nil
+	I.mu.Lock()
+	defer I.mu.Unlock()
+
+	if rs := I.currentRuleSet(); rs != nil {
+		newRs := rs.clone()
+		newRs.detectorMap = make(map[int32]detector.API)
+		I.storeRuleSet(newRs)
 	}
 	return nil
 }
@@ -237,7 +304,7 @@ func (I *Engine) interfaceToStr(in interface{}) string {
 
 // loadDefCfg from the embedded resources
 func (I *Engine) loadDefCfg() error {
-	if confObj, err := conf.NewDlpConf(DefConf); err == nil {
+	if confObj, err := conf.NewDlpConf(DefConf, I.confOptions()...); err == nil {
 		return I.applyConfigImpl(confObj)
 	} else {
 		return err
@@ -260,13 +327,10 @@ func B2S(b []byte) string {
 	return *(*string)(unsafe.Pointer(&b))
 }
 
-func S2B(s string) (b []byte) {
-	/* #nosec G103 */
-	bh := (*reflect.SliceHeader)(unsafe.Pointer(&b)) // nolint: govet
+func S2B(s string) []byte {
 	/* #nosec G103 */
-	sh := *(*reflect.StringHeader)(unsafe.Pointer(&s)) // nolint: govet
-	bh.Data = sh.Data
-	bh.Len = sh.Len
-	bh.Cap = sh.Len
-	return b
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
 }
@@ -2,7 +2,14 @@
 package dlp
 
 import (
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
 	"github.com/laojianzi/godlp/conf"
+	"github.com/laojianzi/godlp/header"
 )
 
 // public func
@@ -11,7 +18,7 @@ import (
 // 传入conf string 进行配置
 func (I *Engine) ApplyConfig(confString string) error {
 	defer I.recoveryImpl()
-	if confObj, err := conf.NewDlpConf(confString); err == nil {
+	if confObj, err := conf.NewDlpConf(confString, I.confOptions()...); err == nil {
 		return I.applyConfigImpl(confObj)
 	} else {
 		return err
@@ -24,7 +31,7 @@ func (I *Engine) ApplyConfigFile(filePath string) error {
 	defer I.recoveryImpl()
 
 	var retErr error
-	if confObj, err := conf.NewDlpConfByPath(filePath); err == nil {
+	if confObj, err := conf.NewDlpConfByPath(filePath, I.confOptions()...); err == nil {
 		retErr = I.applyConfigImpl(confObj)
 	} else {
 		retErr = err
@@ -36,10 +43,223 @@ func (I *Engine) ApplyConfigDefault() error {
 	return I.loadDefCfg()
 }
 
+// ApplyConfigLayers applies the default config overlaid by private layers in order,
+// a layer may override fields of default rules by RuleID and add private rules with RuleID >= 10000
+// 传入私有配置 string 列表，依次叠加在默认配置上进行配置
+func (I *Engine) ApplyConfigLayers(layers ...string) error {
+	defer I.recoveryImpl()
+	if confObj, err := conf.NewDlpConfLayersWithOptions(append([]string{DefConf}, layers...),
+		I.confOptions()...); err == nil {
+		return I.applyConfigImpl(confObj)
+	} else {
+		return err
+	}
+}
+
+// ApplyConfigLayerFiles applies the default config overlaid by private layer files in order
+// 传入私有配置文件列表，依次叠加在默认配置上进行配置
+func (I *Engine) ApplyConfigLayerFiles(filePaths ...string) error {
+	defer I.recoveryImpl()
+
+	layers := make([]string, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		if len(filePath) == 0 {
+			return header.ErrConfPathEmpty
+		}
+		fileData, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, string(fileData))
+	}
+	return I.ApplyConfigLayers(layers...)
+}
+
+// GetEffectiveConf returns the config in use in YAML format, such as the merged config of ApplyConfigLayers
+// 返回当前生效的配置
+func (I *Engine) GetEffectiveConf() (string, error) {
+	rs := I.currentRuleSet()
+	if rs == nil || rs.confObj == nil {
+		return "", header.ErrHasNotConfigured
+	}
+	out, err := yaml.Marshal(rs.confObj)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// WatchConfigFile applies config file, then checks it every interval and reloads it after it is changed.
+// A reload which fails keeps the previous rules, onReload is called with the result of every reload.
+// Call stop or Close() to stop watching, a reload which is running is finished before they return and no
+// reload is applied after them. stop can be called in onReload.
+// 传入filePath 进行配置，并定期检查文件变更后重新加载，加载失败时保留原有规则
+func (I *Engine) WatchConfigFile(
+	filePath string, interval time.Duration, onReload func(err error),
+) (stop func(), err error) {
+	defer I.recoveryImpl()
+
+	if I.hasClosed() {
+		return nil, header.ErrProcessAfterClose
+	}
+	if interval <= 0 {
+		interval = DefWatchInterval
+	}
+	w := &configWatcher{
+		filePath: filePath,
+		interval: interval,
+		onReload: onReload,
+		done:     make(chan struct{}),
+	}
+	// stat before loading, so that a change during loading will be reloaded
+	if info, err := os.Stat(filePath); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	if err := I.ApplyConfigFile(filePath); err != nil {
+		return nil, err
+	}
+
+	I.mu.Lock()
+	if I.hasClosed() { // Close() is called during loading
+		I.mu.Unlock()
+		return nil, header.ErrProcessAfterClose
+	}
+	if I.watchers == nil {
+		I.watchers = make(map[*configWatcher]struct{})
+	}
+	I.watchers[w] = struct{}{}
+	I.mu.Unlock()
+
+	go I.watchConfigImpl(w)
+	return func() {
+		I.mu.Lock()
+		delete(I.watchers, w)
+		I.mu.Unlock()
+		w.stop()
+	}, nil
+}
+
 // private func
 
-// applyConfigImpl sets confObj into Engine, then postLoadConfig(), such as load Detector and MaskWorker
+// confOptions returns options of parsing config, names of registered verifiers are accepted in VAlgo
+// and names of registered maskers are accepted in Mask of rules
+func (I *Engine) confOptions() []conf.Option {
+	I.mu.Lock()
+	defer I.mu.Unlock()
+	opts := make([]conf.Option, 0, 2)
+	if len(I.diyVerifierMap) > 0 {
+		names := make([]string, 0, len(I.diyVerifierMap))
+		for name := range I.diyVerifierMap {
+			names = append(names, name)
+		}
+		opts = append(opts, conf.WithVerifyAlgos(names...))
+	}
+	if len(I.diyMaskerMap) > 0 {
+		names := make([]string, 0, len(I.diyMaskerMap))
+		for name := range I.diyMaskerMap {
+			names = append(names, name)
+		}
+		opts = append(opts, conf.WithMaskNames(names...))
+	}
+	return opts
+}
+
+// applyConfigImpl builds a new rule set from confObj by postLoadConfig(), such as load Detector and MaskWorker,
+// then swaps it into Engine
 func (I *Engine) applyConfigImpl(confObj *conf.DlpConf) error {
-	I.confObj = confObj
-	return I.postLoadConfig()
+	I.mu.Lock()
+	defer I.mu.Unlock()
+
+	// checked under mu, so a watcher or a call which races with Close() does not store rules after it
+	if I.hasClosed() {
+		return header.ErrProcessAfterClose
+	}
+	rs, err := I.postLoadConfig(confObj)
+	if err != nil {
+		return err
+	}
+	I.storeRuleSet(rs)
+	return nil
+}
+
+// configWatcher stores the state of a WatchConfigFile call
+type configWatcher struct {
+	filePath string
+	interval time.Duration
+	onReload func(err error)
+	modTime  time.Time // modTime and size of the last loaded file, size is -1 if the file is missing
+	size     int64
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.Mutex // serializes a reload and stop
+	stopped  bool       // guarded by mu
+}
+
+// stop will stop the watcher and wait for a reload which is running, it can be called more than once,
+// caller must not hold Engine.mu, which is required by the reload
+func (w *configWatcher) stop() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	w.mu.Lock()
+	w.stopped = true
+	w.mu.Unlock()
+}
+
+// notify calls onReload if it is set
+func (w *configWatcher) notify(err error) {
+	if w.onReload != nil {
+		w.onReload(err)
+	}
+}
+
+// watchConfigImpl checks config file every interval until watcher is stopped
+func (I *Engine) watchConfigImpl(w *configWatcher) {
+	defer I.recoveryImpl()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			I.reloadConfigFile(w)
+		}
+	}
+}
+
+// reloadConfigFile reloads config file if its modTime or size is changed, onReload is called without w.mu,
+// so that it can call stop
+func (I *Engine) reloadConfigFile(w *configWatcher) {
+	if changed, err := I.reloadConfigFileImpl(w); changed {
+		w.notify(err)
+	}
+}
+
+// reloadConfigFileImpl implements reloadConfigFile under w.mu, changed is false if there is nothing to notify
+func (I *Engine) reloadConfigFileImpl(w *configWatcher) (changed bool, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return false, nil
+	}
+	info, err := os.Stat(w.filePath)
+	if err != nil {
+		if w.size != -1 { // only notify once until the file comes back
+			w.modTime, w.size = time.Time{}, -1
+			return true, err
+		}
+		return false, nil
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size { // not changed
+		return false, nil
+	}
+
+	w.modTime, w.size = info.ModTime(), info.Size()
+	confObj, err := conf.NewDlpConfByPath(w.filePath, I.confOptions()...)
+	if err == nil {
+		err = I.applyConfigImpl(confObj)
+	}
+	return true, err
 }
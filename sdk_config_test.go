@@ -0,0 +1,312 @@
+package dlp_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/laojianzi/godlp/conf"
+	"github.com/laojianzi/godlp/header"
+)
+
+func TestEngine_WatchConfigFile(t *testing.T) {
+	eng := newTestEngine(t)
+	confPath := filepath.Join(t.TempDir(), "conf.yml")
+	writeConf := func(content string, modTime time.Time) {
+		if err := os.WriteFile(confPath, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// make sure the change is visible even if the file system has coarse mtime
+		if err := os.Chtimes(confPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConf(eng.GetDefaultConf(), time.Now().Add(-time.Hour))
+
+	reloadCh := make(chan error, 4)
+	stop, err := eng.WatchConfigFile(confPath, 10*time.Millisecond, func(err error) {
+		reloadCh <- err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	inputText := "18612341234是我的电话"
+	wantOutputText := "186******34是我的电话"
+	waitReload := func() error {
+		select {
+		case err := <-reloadCh:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("reload timeout")
+		}
+		return nil
+	}
+
+	// broken config keeps the previous rules
+	writeConf("Global:\n  ApiVersion: v1\n", time.Now().Add(-time.Minute))
+	if err = waitReload(); err == nil {
+		t.Fatal("reload broken config got nil error")
+	}
+	if out, _, err := eng.DeIdentify(inputText); err != nil || out != wantOutputText {
+		t.Fatalf("DeIdentify() after failed reload got = %v, %v, want = %v", out, err, wantOutputText)
+	}
+
+	// disable phone rule
+	newConf := strings.Replace(eng.GetDefaultConf(), "DisableRules: []", "DisableRules: [1]", 1)
+	writeConf(newConf, time.Now())
+	if err = waitReload(); err != nil {
+		t.Fatal(err)
+	}
+	if out, _, err := eng.DeIdentify(inputText); err != nil || out != inputText {
+		t.Fatalf("DeIdentify() after reload got = %v, %v, want = %v", out, err, inputText)
+	}
+}
+
+func TestEngine_WatchConfigFileStop(t *testing.T) {
+	eng := newTestEngine(t)
+	confPath := filepath.Join(t.TempDir(), "conf.yml")
+	writeConf := func(i int) {
+		content := strings.Replace(eng.GetDefaultConf(), "DisableRules: []", fmt.Sprintf("DisableRules: [%d]", i), 1)
+		if err := os.WriteFile(confPath, []byte(content), 0o600); err != nil {
+			t.Error(err)
+		}
+	}
+	writeConf(1)
+	reloaded := make(chan struct{}, 1)
+	var stop func()
+	stop, err := eng.WatchConfigFile(confPath, time.Millisecond, func(err error) {
+		select {
+		case reloaded <- struct{}{}:
+		default:
+		}
+		if err == nil {
+			stop() // stop in onReload does not dead lock
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keep changing the file, no reload is applied after stop returns
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 2; i < 40; i++ {
+			writeConf(i)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload timeout")
+	}
+	stop()
+	want, err := eng.GetEffectiveConf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	time.Sleep(10 * time.Millisecond)
+	if got, _ := eng.GetEffectiveConf(); got != want {
+		t.Errorf("GetEffectiveConf() changed after stop() returned")
+	}
+}
+
+func TestEngine_ApplyConfigAfterClose(t *testing.T) {
+	eng := newTestEngine(t)
+	confPath := filepath.Join(t.TempDir(), "conf.yml")
+	err := os.WriteFile(confPath, []byte(eng.GetDefaultConf()), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.Close()
+
+	if err = eng.ApplyConfig(eng.GetDefaultConf()); !errors.Is(err, header.ErrProcessAfterClose) {
+		t.Errorf("ApplyConfig() after Close() got err = %v, want %v", err, header.ErrProcessAfterClose)
+	}
+	if _, err = eng.WatchConfigFile(confPath, time.Millisecond, nil); !errors.Is(err, header.ErrProcessAfterClose) {
+		t.Errorf("WatchConfigFile() after Close() got err = %v, want %v", err, header.ErrProcessAfterClose)
+	}
+	if _, err = eng.GetEffectiveConf(); !errors.Is(err, header.ErrHasNotConfigured) {
+		t.Errorf("GetEffectiveConf() after Close() got err = %v, want %v", err, header.ErrHasNotConfigured)
+	}
+}
+
+func TestEngine_ApplyConfigLayers(t *testing.T) {
+	eng := newTestEngine(t)
+	overlay := `
+Global:
+  DisableRules: [2]
+Rules:
+  - RuleID: 1
+    Mask: ExampleTAG
+  - RuleID: 10001
+    InfoType: ORDER_ID
+    Level: L2
+    Detect:
+      VReg:
+        - "ORD-\\d{6}"
+    Mask: ALL
+`
+	err := eng.ApplyConfigLayers(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputText := "18612341234是我的电话, 邮件abcd@abcd.com, 订单ORD-123456"
+	wantOutputText := "<PHONE>是我的电话, 邮件abcd@abcd.com, 订单**********"
+	out, _, err := eng.DeIdentify(inputText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != wantOutputText {
+		t.Errorf("DeIdentify() \ngot = %v, \nwant = %v", out, wantOutputText)
+	}
+
+	effective, err := eng.GetEffectiveConf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(effective, "RuleID: 10001") {
+		t.Errorf("GetEffectiveConf() does not contain the private rule:\n%s", effective)
+	}
+	if strings.Contains(effective, "Require: []") {
+		t.Errorf("GetEffectiveConf() writes empty Require of rules")
+	}
+
+	// default RuleID range is reserved
+	badOverlay := "Rules:\n  - RuleID: 9999\n    InfoType: BAD\n    Detect:\n      VDict: [bad]\n"
+	if err = eng.ApplyConfigLayers(badOverlay); !errors.Is(err, header.ErrConfVerifyFailed) {
+		t.Errorf("ApplyConfigLayers() got err = %v, want %v", err, header.ErrConfVerifyFailed)
+	}
+
+	// problems of layered configs have no line numbers of the merged YAML
+	badOverlay = "Rules:\n  - RuleID: 10002\n    InfoType: BAD\n    Detect:\n      VReg: [\"(bad\"]\n"
+	var verifyErrs conf.VerifyErrors
+	if err = eng.ApplyConfigLayers(badOverlay); !errors.As(err, &verifyErrs) {
+		t.Fatalf("ApplyConfigLayers() got err = %v, want conf.VerifyErrors", err)
+	}
+	for _, e := range verifyErrs {
+		if e.RuleID != 10002 || e.Line != 0 {
+			t.Errorf("ApplyConfigLayers() got problem %+v, want RuleID 10002 without line", *e)
+		}
+	}
+
+	// the same RuleID or RuleName twice in one layer is a mistake, overriding across layers is not
+	dupOverlay := `
+MaskRules:
+  - RuleName: ExampleTAG
+    MaskType: TAG
+  - RuleName: ExampleTAG
+    MaskType: CHAR
+Rules:
+  - RuleID: 1
+    Mask: ExampleTAG
+  - RuleID: 1
+    Mask: ALL
+`
+	verifyErrs = nil
+	if err = eng.ApplyConfigLayers(dupOverlay); !errors.As(err, &verifyErrs) {
+		t.Fatalf("ApplyConfigLayers() got err = %v, want conf.VerifyErrors", err)
+	}
+	var dupRuleID, dupRuleName bool
+	for _, e := range verifyErrs {
+		dupRuleID = dupRuleID || (e.RuleID == 1 && e.Field == "RuleID")
+		dupRuleName = dupRuleName || (e.RuleName == "ExampleTAG" && e.Field == "RuleName")
+	}
+	if len(verifyErrs) != 2 || !dupRuleID || !dupRuleName {
+		t.Errorf("ApplyConfigLayers() got err = %v, want duplicated RuleID:1 and RuleName:ExampleTAG", err)
+	}
+	if err = eng.ApplyConfigLayers(overlay, "Rules:\n  - RuleID: 1\n    Mask: ALL\n"); err != nil {
+		t.Errorf("ApplyConfigLayers() with RuleID in two layers got err = %v", err)
+	}
+}
+
+func TestEngine_ApplyConfigRegisteredMasker(t *testing.T) {
+	eng := newTestEngine(t)
+
+	overlay := "Rules:\n  - RuleID: 1\n    Mask: MY_PHONE\n"
+	err := eng.ApplyConfigLayers(overlay)
+	if !errors.Is(err, header.ErrConfVerifyFailed) {
+		t.Fatalf("ApplyConfigLayers() with unknown Mask got err = %v, want %v", err, header.ErrConfVerifyFailed)
+	}
+	// a rule can refer to a masker of RegisterMasker
+	if err = eng.RegisterMasker("MY_PHONE", func(string) (string, error) { return "<MY_PHONE>", nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err = eng.ApplyConfigLayers(overlay); err != nil {
+		t.Fatal(err)
+	}
+	out, _, err := eng.DeIdentify("18612341234是我的电话")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<MY_PHONE>是我的电话"; out != want {
+		t.Errorf("DeIdentify() got = %q, want = %q", out, want)
+	}
+}
+
+func TestEngine_ApplyConfigVerifyErrors(t *testing.T) {
+	eng := newTestEngine(t)
+	badConf := `Global:
+  ApiVersion: v2
+  Mode: release
+  DisableRules: [3]
+MaskRules:
+  - RuleName: PHONE
+    MaskType: CHAR
+  - RuleName: PHONE
+    MaskType: CHAR
+Rules:
+  - RuleID: 1
+    Detect:
+      VReg:
+        - "(\\d{11}"
+    Verify:
+      VAlgo: [UNKNOWN]
+    Mask: NOT_EXIST
+  - RuleID: 1
+    Detect:
+      VDict: [abc]
+`
+	err := eng.ApplyConfig(badConf)
+	if !errors.Is(err, header.ErrConfVerifyFailed) || !errors.Is(err, header.ErrRegexCompileFailed) {
+		t.Fatalf("ApplyConfig() got err = %v, want %v and %v", err, header.ErrConfVerifyFailed,
+			header.ErrRegexCompileFailed)
+	}
+	var verifyErrs conf.VerifyErrors
+	if !errors.As(err, &verifyErrs) {
+		t.Fatalf("ApplyConfig() got err type %T, want conf.VerifyErrors", err)
+	}
+
+	type problem struct {
+		ruleID   int32
+		ruleName string
+		field    string
+		line     int
+	}
+	want := []problem{
+		{0, "PHONE", "RuleName", 8},
+		{1, "", "Detect.VReg", 13},
+		{1, "", "Verify.VAlgo", 16},
+		{1, "", "Mask", 17},
+		{1, "", "RuleID", 18},
+		{0, "", "Global.DisableRules", 4},
+	}
+	if len(verifyErrs) != len(want) {
+		t.Fatalf("ApplyConfig() got %d problems, want %d:\n%v", len(verifyErrs), len(want), err)
+	}
+	for i, e := range verifyErrs {
+		got := problem{e.RuleID, e.RuleName, e.Field, e.Line}
+		if got != want[i] {
+			t.Errorf("problem[%d] got = %+v, want = %+v", i, got, want[i])
+		}
+	}
+}
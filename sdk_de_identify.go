@@ -2,7 +2,9 @@
 package dlp
 
 import (
-	"fmt"
+	"context"
+	"io"
+	"time"
 
 	"github.com/laojianzi/godlp/header"
 	"github.com/laojianzi/godlp/internal/json"
@@ -11,9 +13,20 @@ import (
 // DeIdentify detects string firstly, then return masked string and results
 // 对string先识别，然后按规则进行打码
 // public func
-func (I *Engine) DeIdentify(inputText string) (outputText string, retResults []*header.DetectResult, retErr error) {
+func (I *Engine) DeIdentify(inputText string, opts ...header.DetectOption) (string, []*header.DetectResult, error) {
+	return I.DeIdentifyContext(context.Background(), inputText, opts...)
+}
+
+// DeIdentifyContext is DeIdentify with ctx, detection stops once ctx is done,
+// text masked by results found so far is returned with ctx.Err()
+// 带 context 的 DeIdentify，ctx 结束后停止识别，返回按已识别结果打码的文本和 ctx.Err()
+func (I *Engine) DeIdentifyContext(
+	ctx context.Context, inputText string, opts ...header.DetectOption,
+) (outputText string, retResults []*header.DetectResult, retErr error) {
 	defer I.recoveryImpl()
-	if !I.hasConfigured() { // not configured
+	defer I.stats.observe(APIDeIdentify, time.Now(), len(inputText), &retResults, &retErr)
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if I.hasClosed() {
@@ -23,19 +36,68 @@ func (I *Engine) DeIdentify(inputText string) (outputText string, retResults []*
 		return inputText, nil, header.ErrOnlyForLog
 	}
 
-	if len(inputText) > DefMaxInput {
-		return inputText, nil, fmt.Errorf("DefMaxInput: %d , %w", DefMaxInput, header.ErrMaxInputLimit)
+	if err := checkLimit("MaxInput", rs.limits.MaxInput, len(inputText)); err != nil {
+		return inputText, nil, err
 	}
-	outputText, retResults, retErr = I.deIdentifyImpl(inputText)
+	outputText, retResults, retErr = I.deIdentifyImpl(ctx, rs.withOptions(opts), inputText)
+	return
+}
+
+// DeIdentifyStream detects input from r, then writes masked text into w, input size is not limited
+// 对io.Reader进行流式识别，然后将打码后的文本写入io.Writer，不限制输入大小
+func (I *Engine) DeIdentifyStream(r io.Reader, w io.Writer, opts ...header.DetectOption) error {
+	return I.DeIdentifyStreamContext(context.Background(), r, w, opts...)
+}
+
+// DeIdentifyStreamContext is DeIdentifyStream with ctx, it stops once ctx is done and ctx.Err() is returned
+// 带 context 的 DeIdentifyStream，ctx 结束后停止处理并返回 ctx.Err()
+func (I *Engine) DeIdentifyStreamContext(ctx context.Context, r io.Reader, w io.Writer,
+	opts ...header.DetectOption,
+) (retErr error) {
+	defer I.recoveryImpl()
+	cr := &countReader{Reader: r}
+	start := time.Now()
+	defer func() { I.stats.observe(APIDeIdentifyStream, start, cr.n, nil, &retErr) }()
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
+		panic(header.ErrHasNotConfigured)
+	}
+	if I.hasClosed() {
+		return header.ErrProcessAfterClose
+	}
+	if I.isOnlyForLog() {
+		return header.ErrOnlyForLog
+	}
+
+	var out []byte
+	rs = rs.withOptions(opts)
+	retErr = I.detectStreamImpl(ctx, rs, cr, func(chunk []byte, chunkPos int, results []*header.DetectResult) error {
+		I.stats.addHits(results)
+		out = appendByResult(out[:0], chunk, chunkPos, results)
+		_, err := w.Write(out)
+		return err
+	})
 	return
 }
 
 // DeIdentifyMap detects KV map firstly,then return masked map
 // 对map[string]string先识别，然后按规则进行打码
-func (I *Engine) DeIdentifyMap(inputMap map[string]string) (map[string]string, []*header.DetectResult, error) {
+func (I *Engine) DeIdentifyMap(inputMap map[string]string, opts ...header.DetectOption,
+) (map[string]string, []*header.DetectResult, error) {
+	return I.DeIdentifyMapContext(context.Background(), inputMap, opts...)
+}
+
+// DeIdentifyMapContext is DeIdentifyMap with ctx, detection stops once ctx is done,
+// map masked by results found so far is returned with ctx.Err()
+// 带 context 的 DeIdentifyMap，ctx 结束后停止识别，返回按已识别结果打码的map和 ctx.Err()
+func (I *Engine) DeIdentifyMapContext(
+	ctx context.Context, inputMap map[string]string, opts ...header.DetectOption,
+) (outMap map[string]string, retResults []*header.DetectResult, retErr error) {
 	defer I.recoveryImpl()
+	defer I.stats.observe(APIDeIdentifyMap, time.Now(), mapSize(inputMap), &retResults, &retErr)
 
-	if !I.hasConfigured() { // not configured
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 
@@ -43,31 +105,44 @@ func (I *Engine) DeIdentifyMap(inputMap map[string]string) (map[string]string, [
 		return nil, nil, header.ErrProcessAfterClose
 	}
 
-	if len(inputMap) > DefMaxItem {
-		return inputMap, nil, fmt.Errorf("DefMaxItem: %d , %w", DefMaxItem, header.ErrMaxInputLimit)
+	if err := checkLimit("MaxItem", rs.limits.MaxItem, len(inputMap)); err != nil {
+		return inputMap, nil, err
 	}
 
-	return I.deIdentifyMapImpl(inputMap)
+	return I.deIdentifyMapImpl(ctx, rs.withOptions(opts), inputMap)
 }
 
 // DeIdentifyJSON detects JSON firstly, then return masked json object in string format and results
 // 对jsonText先识别，然后按规则进行打码，返回打码后的JSON string
-func (I *Engine) DeIdentifyJSON(jsonText string) (outStr string, retResults []*header.DetectResult, retErr error) {
+func (I *Engine) DeIdentifyJSON(jsonText string, opts ...header.DetectOption) (string, []*header.DetectResult, error) {
+	return I.DeIdentifyJSONContext(context.Background(), jsonText, opts...)
+}
+
+// DeIdentifyJSONContext is DeIdentifyJSON with ctx, detection stops once ctx is done,
+// json masked by results found so far is returned with ctx.Err()
+// 带 context 的 DeIdentifyJSON，ctx 结束后停止识别，
+// 返回按已识别结果打码的JSON string和 ctx.Err()
+func (I *Engine) DeIdentifyJSONContext(
+	ctx context.Context, jsonText string, opts ...header.DetectOption,
+) (outStr string, retResults []*header.DetectResult, retErr error) {
 	defer I.recoveryImpl()
+	defer I.stats.observe(APIDeIdentifyJSON, time.Now(), len(jsonText), &retResults, &retErr)
 
-	if !I.hasConfigured() { // not configured
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if I.hasClosed() {
 		return jsonText, nil, header.ErrProcessAfterClose
 	}
 	outStr = jsonText
-	results, kvMap, err := I.detectJSONImpl(jsonText)
-	if err != nil {
+	results, kvMap, err := I.detectJSONImpl(ctx, rs.withOptions(opts), jsonText)
+	if kvMap == nil { // json syntax error
 		return "", nil, err
 	}
 
-	retResults = results
+	// err is ctx.Err() here, results are partial
+	retResults, retErr = results, err
 	var jsonObj interface{}
 	if err = json.Unmarshal([]byte(jsonText), &jsonObj); err != nil {
 		return "", nil, err
@@ -90,7 +165,8 @@ func (I *Engine) DeIdentifyJSON(jsonText string) (outStr string, retResults []*h
 func (I *Engine) DeIdentifyJSONByResult(jsonText string, detectResults []*header.DetectResult) (string, error) {
 	defer I.recoveryImpl()
 	// have to use closure to pass retResults parameters
-	if !I.hasConfigured() { // not configured
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if I.hasClosed() {
@@ -114,16 +190,16 @@ func (I *Engine) DeIdentifyJSONByResult(jsonText string, detectResults []*header
 
 // deIdentifyImpl implements DeIdentify string
 // private func
-func (I *Engine) deIdentifyImpl(inputText string) (outputText string, retResults []*header.DetectResult, retErr error) {
+func (I *Engine) deIdentifyImpl(
+	ctx context.Context, rs *ruleSet, inputText string,
+) (outputText string, retResults []*header.DetectResult, retErr error) {
 	outputText = inputText // default same text
 
-	if arr, err := I.detectImpl(inputText); err == nil {
-		retResults = arr
-		if out, err := I.deIdentifyByResult(inputText, retResults); err == nil {
-			outputText = out
-		} else {
-			retErr = err
-		}
+	// if ctx is done, arr contains results found so far, mask them anyway
+	arr, retErr := I.detectImpl(ctx, rs, inputText)
+	retResults = arr
+	if out, err := I.deIdentifyByResult(inputText, retResults); err == nil {
+		outputText = out
 	} else {
 		retErr = err
 	}
@@ -131,14 +207,13 @@ func (I *Engine) deIdentifyImpl(inputText string) (outputText string, retResults
 }
 
 // deIdentifyMapImpl implements DeIdentifyMap
-func (I *Engine) deIdentifyMapImpl(inputMap map[string]string) (map[string]string, []*header.DetectResult, error) {
-	results, err := I.detectMapImpl(inputMap)
-	if err != nil {
-		return inputMap, nil, err
-	}
-
+func (I *Engine) deIdentifyMapImpl(
+	ctx context.Context, rs *ruleSet, inputMap map[string]string,
+) (map[string]string, []*header.DetectResult, error) {
+	// err is only ctx.Err(), results found so far are masked anyway
+	results, err := I.detectMapImpl(ctx, rs, inputMap)
 	if len(results) == 0 { // detect nothing
-		return inputMap, results, nil
+		return inputMap, results, err
 	}
 
 	outMap := inputMap
@@ -153,27 +228,49 @@ func (I *Engine) deIdentifyMapImpl(inputMap map[string]string) (map[string]strin
 		}
 	}
 
-	return outMap, results, nil
+	return outMap, results, err
 }
 
 // deIdentifyByResult concatenate MaskText
 func (I *Engine) deIdentifyByResult(in string, arr []*header.DetectResult) (string, error) {
 	out := make([]byte, 0, len(in)+8)
+	out = appendByResult(out, S2B(in), 0, arr)
+	outStr := B2S(out)
+	return outStr, nil
+}
+
+// appendByResult appends in to out with MaskText of results, in starts at offset of the position of results.
+// results are sorted by ByteStart and may overlap, such as the ones of Overlap ALL, the union of their spans is
+// masked: a result inside an outer one is masked by the outer one, and the part of a result after the end of
+// the one before it is masked by the tail of its MaskText if MaskText keeps the length, otherwise by MaskText.
+func appendByResult(out []byte, in []byte, offset int, arr []*header.DetectResult) []byte {
 	pos := 0
-	inArr := S2B(in)
-	for _, res := range arr {
-		if pos < res.ByteStart {
-			out = append(out, inArr[pos:res.ByteStart]...)
+	for i := 0; i < len(arr); i++ {
+		res := arr[i]
+		start, end := res.ByteStart-offset, res.ByteEnd-offset
+		if end <= pos && start < pos { // inside the masked span
+			continue
 		}
-
-		out = append(out, []byte(res.MaskText)...)
-		pos = res.ByteEnd
+		if pos <= start {
+			// the outermost result of the same start is masked, the ones inside it are skipped above
+			for i+1 < len(arr) && arr[i+1].ByteStart == res.ByteStart && arr[i+1].ByteEnd >= res.ByteEnd {
+				i++
+				res = arr[i]
+			}
+			start, end = res.ByteStart-offset, res.ByteEnd-offset
+			out = append(out, in[pos:start]...)
+			out = append(out, res.MaskText...)
+		} else if len(res.MaskText) == end-start { // overlaps the end of the masked span
+			out = append(out, res.MaskText[pos-start:]...)
+		} else {
+			out = append(out, res.MaskText...)
+		}
+		pos = end
 	}
 	if pos < len(in) {
-		out = append(out, inArr[pos:]...)
+		out = append(out, in[pos:]...)
 	}
-	outStr := B2S(out)
-	return outStr, nil
+	return out
 }
 
 // resultsToMap convert results array into Map[Key]=MaskText
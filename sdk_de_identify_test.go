@@ -1,9 +1,13 @@
 package dlp_test
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	dlp "github.com/laojianzi/godlp"
+	"github.com/laojianzi/godlp/header"
 )
 
 func TestEngine_DeIdentify(t *testing.T) {
@@ -36,3 +40,55 @@ mac地址 06-06-06-**-**-**
 		t.Errorf("DeIdentify() \ngot = %v, \nwant = %v", gotOutputText, wantOutputText)
 	}
 }
+
+func TestEngine_DeIdentifyStream(t *testing.T) {
+	eng := newTestEngine(t)
+	// regex rules are slow on long lines with -race, phone and email rules are enough for this test
+	err := eng.ApplyConfig(strings.Replace(eng.GetDefaultConf(), "EnableRules: []", "EnableRules: [1, 2]", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a long line makes sure results straddle chunk boundaries, and some short lines
+	var sb strings.Builder
+	filler := strings.Repeat(" ", 1000)
+	for i := 0; sb.Len() < 3*dlp.DefStreamChunkSize; i++ {
+		sb.WriteString(fmt.Sprintf("第%d个用户 email:user%d@abcd.com phone:186%08d; %s", i, i, i, filler))
+	}
+	sb.WriteString("\n我的邮件是abcd@abcd.com,\n18612341234是我的电话\n")
+	inputText := sb.String()
+
+	wantOutputText, wantResults, err := eng.DeIdentify(inputText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err = eng.DeIdentifyStream(strings.NewReader(inputText), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != wantOutputText {
+		t.Errorf("DeIdentifyStream() output is different from DeIdentify(), got len %d, want len %d",
+			out.Len(), len(wantOutputText))
+	}
+
+	gotResults := make([]*header.DetectResult, 0, len(wantResults))
+	if err = eng.DetectStream(strings.NewReader(inputText), func(res *header.DetectResult) error {
+		gotResults = append(gotResults, res)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(gotResults) != len(wantResults) {
+		t.Fatalf("DetectStream() got %d results, want %d", len(gotResults), len(wantResults))
+	}
+	for i, res := range gotResults {
+		want := wantResults[i]
+		if res.RuleID != want.RuleID || res.ByteStart != want.ByteStart || res.ByteEnd != want.ByteEnd {
+			t.Fatalf("DetectStream() result %d got = %+v, want = %+v", i, res, want)
+		}
+		if inputText[res.ByteStart:res.ByteEnd] != res.Text {
+			t.Fatalf("DetectStream() result %d text %s is not at [%d, %d)", i, res.Text, res.ByteStart, res.ByteEnd)
+		}
+	}
+}
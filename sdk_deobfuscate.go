@@ -0,0 +1,245 @@
+// Package dlp sdk_deobfuscate.go implements the optional de-obfuscation pass of text detection
+package dlp
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/laojianzi/godlp/header"
+)
+
+// const var for de-obfuscation
+const (
+	// ExtInfoObfuscated is the key of DetectResult.ExtInfo, it is "true" if the result is found in obfuscated text
+	ExtInfoObfuscated = "Obfuscated"
+	// DefObfuscatedMinDigits is the min number of digits in a run of spaced or spelled-out digits
+	DefObfuscatedMinDigits = 7
+	// defObfuscatedMaxSep is the max length in bytes of separators between two digits of a run
+	defObfuscatedMaxSep = 3
+)
+
+// separators of emails written as "abc at example dot com", "abc[at]example(dot)com" or "abc @ example.com"
+const (
+	obfuscatedAt  = `(?:\s*[\[({<]\s*(?:at|@)\s*[\])}>]\s*|\s+at\s+|\s*@\s*)`
+	obfuscatedDot = `(?:\s*[\[({<]\s*(?:dot|\.)\s*[\])}>]\s*|\s+dot\s+|\.)`
+)
+
+var (
+	reObfuscatedEmail = regexp.MustCompile(`(?i)[a-z0-9][a-z0-9._%+\-]*` + obfuscatedAt +
+		`[a-z0-9][a-z0-9\-]*(?:` + obfuscatedDot + `[a-z0-9][a-z0-9\-]*)+`)
+	reObfuscatedAt  = regexp.MustCompile(`(?i)` + obfuscatedAt)
+	reObfuscatedDot = regexp.MustCompile(`(?i)` + obfuscatedDot)
+)
+
+// spelledDigits are spelled-out digits in Chinese, 幺 and 两 are used when reading phone numbers
+var spelledDigits = map[rune]byte{
+	'零': '0', '〇': '0', '幺': '1', '一': '1', '二': '2', '两': '2', '三': '3', '四': '4',
+	'五': '5', '六': '6', '七': '7', '八': '8', '九': '9',
+	'壹': '1', '贰': '2', '叁': '3', '肆': '4', '伍': '5', '陆': '6', '柒': '7', '捌': '8', '玖': '9',
+}
+
+// spelledWords are spelled-out digits in English, they are matched as whole words case-insensitively
+var spelledWords = map[string]byte{
+	"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+	"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+}
+
+// obfuscatedEdit replaces line[st:ed] with repl in de-obfuscated text
+type obfuscatedEdit struct {
+	st, ed int
+	repl   string
+}
+
+// spanMap maps byte offsets of de-obfuscated text back to the text before de-obfuscation
+type spanMap struct {
+	starts []int // starts[i] is the offset in src of the first byte which produces byte i of de-obfuscated text
+	ends   []int // ends[i] is the offset in src after the last byte which produces byte i
+}
+
+// private func
+
+// detectObfuscated detects the de-obfuscated text of line, line is the output of detectPre and lineResults are
+// found in it. Results which are only found after de-obfuscation are merged into lineResults, their positions
+// are in line and ExtInfo[ExtInfoObfuscated] is "true". Only VALUE rules are used, keys are not obfuscated,
+// and stats are not counted again for the same line.
+func (I *Engine) detectObfuscated(ctx context.Context, rs *ruleSet, line []byte,
+	lineResults []*header.DetectResult,
+) []*header.DetectResult {
+	text, sm := deobfuscate(line)
+	if sm == nil { // nothing is obfuscated
+		return lineResults
+	}
+	results, _ := I.detectBytes(ctx, rs.quietView(), text)
+	results = rs.filterScore(results)
+	found := make([]*header.DetectResult, 0, len(results))
+	for _, res := range results {
+		st, ed := res.ByteStart, res.ByteEnd
+		if st < 0 || st >= ed || ed > len(text) {
+			continue
+		}
+		res.ByteStart, res.ByteEnd = sm.starts[st], sm.ends[ed-1]
+		if bytes.Equal(line[res.ByteStart:res.ByteEnd], text[st:ed]) || overlapResults(res, lineResults) {
+			continue // found by detectProcess on line
+		}
+		extInfo := make(map[string]string, len(res.ExtInfo)+1)
+		for k, v := range res.ExtInfo {
+			extInfo[k] = v
+		}
+		extInfo[ExtInfoObfuscated] = "true"
+		res.ExtInfo = extInfo
+		found = append(found, res)
+	}
+	if len(found) == 0 {
+		return lineResults
+	}
+	return I.mergeResults(rs, lineResults, found)
+}
+
+// overlapResults checks whether res overlaps one of results
+func overlapResults(res *header.DetectResult, results []*header.DetectResult) bool {
+	for _, item := range results {
+		if res.ByteStart < item.ByteEnd && item.ByteStart < res.ByteEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// deobfuscate canonicalizes emails written in "at/dot" notation and runs of digits which are spaced or
+// spelled out, such as "1 8 6-1234-1234", "one eight six ..." and "幺八六...", a nil spanMap is returned if
+// nothing is changed
+func deobfuscate(line []byte) ([]byte, *spanMap) {
+	edits := make([]obfuscatedEdit, 0, DefResultSize)
+	emails := reObfuscatedEmail.FindAllIndex(line, -1)
+	for _, loc := range emails {
+		edits = appendEmailEdits(edits, line, loc[0], loc[1])
+	}
+	edits = appendDigitEdits(edits, line, emails)
+	if len(edits) == 0 {
+		return line, nil
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].st < edits[j].st })
+
+	out := make([]byte, 0, len(line))
+	sm := &spanMap{starts: make([]int, 0, len(line)), ends: make([]int, 0, len(line))}
+	pos := 0
+	for _, e := range edits {
+		for ; pos < e.st; pos++ {
+			out = append(out, line[pos])
+			sm.starts, sm.ends = append(sm.starts, pos), append(sm.ends, pos+1)
+		}
+		for i := 0; i < len(e.repl); i++ {
+			out = append(out, e.repl[i])
+			sm.starts, sm.ends = append(sm.starts, e.st), append(sm.ends, e.ed)
+		}
+		pos = e.ed
+	}
+	for ; pos < len(line); pos++ {
+		out = append(out, line[pos])
+		sm.starts, sm.ends = append(sm.starts, pos), append(sm.ends, pos+1)
+	}
+	return out, sm
+}
+
+// appendEmailEdits appends edits which replace "at" and "dot" separators in line[st:ed] with '@' and '.'
+func appendEmailEdits(edits []obfuscatedEdit, line []byte, st int, ed int) []obfuscatedEdit {
+	email := line[st:ed]
+	at := reObfuscatedAt.FindIndex(email)
+	if at == nil {
+		return edits
+	}
+	edits = appendEdit(edits, line, st+at[0], st+at[1], "@")
+	for _, dot := range reObfuscatedDot.FindAllIndex(email[at[1]:], -1) {
+		edits = appendEdit(edits, line, st+at[1]+dot[0], st+at[1]+dot[1], ".")
+	}
+	return edits
+}
+
+// appendEdit appends an edit if it changes line
+func appendEdit(edits []obfuscatedEdit, line []byte, st int, ed int, repl string) []obfuscatedEdit {
+	if string(line[st:ed]) == repl {
+		return edits
+	}
+	return append(edits, obfuscatedEdit{st: st, ed: ed, repl: repl})
+}
+
+// appendDigitEdits appends edits of runs of at least DefObfuscatedMinDigits digits which are separated by
+// spaces, '-', '.' or '_' or spelled out, runs in skip are ignored
+func appendDigitEdits(edits []obfuscatedEdit, line []byte, skip [][]int) []obfuscatedEdit {
+	var run []obfuscatedEdit // edits of the current run
+	digits, changed := 0, false
+	lastEnd := -1 // end of the last digit of the current run
+	flush := func() {
+		if digits >= DefObfuscatedMinDigits && changed {
+			edits = append(edits, run...)
+		}
+		run, digits, changed, lastEnd = run[:0], 0, false, -1
+	}
+	for i := 0; i < len(line); {
+		if len(skip) > 0 && i >= skip[0][0] {
+			flush()
+			i, skip = skip[0][1], skip[1:]
+			continue
+		}
+		digit, width := digitAt(line, i)
+		if width == 0 {
+			if !isDigitSeparator(line[i]) || lastEnd == -1 || i+1-lastEnd > defObfuscatedMaxSep {
+				flush()
+			}
+			_, w := utf8.DecodeRune(line[i:])
+			i += w
+			continue
+		}
+		if lastEnd != -1 && lastEnd < i { // separators between two digits are removed
+			run = append(run, obfuscatedEdit{st: lastEnd, ed: i})
+			changed = true
+		}
+		if width != 1 || line[i] != digit {
+			run = append(run, obfuscatedEdit{st: i, ed: i + width, repl: string(digit)})
+			changed = true
+		}
+		digits++
+		lastEnd = i + width
+		i += width
+	}
+	flush()
+	return edits
+}
+
+// digitAt returns the digit at line[i] and the width of it, width is 0 if it is not a digit
+func digitAt(line []byte, i int) (byte, int) {
+	if c := line[i]; c >= '0' && c <= '9' {
+		return c, 1
+	}
+	if line[i] >= utf8.RuneSelf {
+		r, width := utf8.DecodeRune(line[i:])
+		if d, ok := spelledDigits[r]; ok {
+			return d, width
+		}
+		return 0, 0
+	}
+	if !isASCIILetter(line[i]) || (i > 0 && isASCIILetter(line[i-1])) {
+		return 0, 0
+	}
+	ed := i
+	for ed < len(line) && isASCIILetter(line[ed]) {
+		ed++
+	}
+	if d, ok := spelledWords[string(bytes.ToLower(line[i:ed]))]; ok {
+		return d, ed - i
+	}
+	return 0, 0
+}
+
+// isDigitSeparator checks whether c can separate digits of an obfuscated number
+func isDigitSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == '-' || c == '.' || c == '_'
+}
+
+// isASCIILetter checks whether c is an ASCII letter
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,64 @@
+package dlp_test
+
+import (
+	"strings"
+	"testing"
+
+	dlp "github.com/laojianzi/godlp"
+	"github.com/laojianzi/godlp/header"
+)
+
+func TestEngine_DetectObfuscated(t *testing.T) {
+	eng := newTestEngine(t)
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"call 1 8 6-1234-1234 now", "call 186******34 now"},
+		{"call one eight six one two three four one two three four ok", "call 186******34 ok"},
+		{"我的电话幺八六幺二三四幺二三四谢谢", "我的电话186******34谢谢"},
+		{"mail abc at example dot com thanks", "mail a**@*********** thanks"},
+		{"mail abc[at]example(dot)com", "mail a**@***********"},
+	}
+	for _, tt := range tests {
+		out, results, err := eng.DeIdentify(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != tt.in || len(results) != 0 {
+			t.Errorf("DeIdentify(%q) without de-obfuscation got = %q, %d results", tt.in, out, len(results))
+		}
+		out, results, err = eng.DeIdentify(tt.in, header.WithDeobfuscation())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != tt.want {
+			t.Errorf("DeIdentify(%q) got = %q, want = %q", tt.in, out, tt.want)
+		}
+		if len(results) != 1 || results[0].ExtInfo[dlp.ExtInfoObfuscated] != "true" {
+			t.Errorf("DeIdentify(%q) got %d results, want 1 obfuscated result", tt.in, len(results))
+		}
+	}
+
+	// Global.Deobfuscate enables it for all calls, results which are not obfuscated are not flagged
+	confString := strings.Replace(eng.GetDefaultConf(), "# Deobfuscate: false", "Deobfuscate: true", 1)
+	err := eng.ApplyConfig(confString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := eng.Detect("call 18612341234 or 1 8 6 1234 5678 now")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Detect() got %d results, want 2", len(results))
+	}
+	if _, ok := results[0].ExtInfo[dlp.ExtInfoObfuscated]; ok {
+		t.Errorf("Detect() got result %q flagged as obfuscated", results[0].Text)
+	}
+	if res := results[1]; res.Text != "18612345678" || res.ExtInfo[dlp.ExtInfoObfuscated] != "true" ||
+		res.ByteStart != 20 || res.ByteEnd != 35 {
+		t.Errorf("Detect() got obfuscated result = %+v, want 18612345678 at [20, 35)", res)
+	}
+}
@@ -3,13 +3,17 @@ package dlp
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"sort"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"github.com/laojianzi/godlp/conf"
 	"github.com/laojianzi/godlp/detector"
 	"github.com/laojianzi/godlp/header"
 	"github.com/laojianzi/godlp/internal/json"
@@ -19,76 +23,229 @@ import (
 
 // Detect find sensitive information for input string
 // 对string进行敏感信息识别
-func (I *Engine) Detect(inputText string) (retResults []*header.DetectResult, retErr error) {
+func (I *Engine) Detect(inputText string, opts ...header.DetectOption) ([]*header.DetectResult, error) {
+	return I.DetectContext(context.Background(), inputText, opts...)
+}
+
+// DetectContext is Detect with ctx, detection stops once ctx is done, results found so far are returned with ctx.Err()
+// 带 context 的 Detect，ctx 结束后停止识别，返回已识别的结果和 ctx.Err()
+func (I *Engine) DetectContext(
+	ctx context.Context, inputText string, opts ...header.DetectOption,
+) (retResults []*header.DetectResult, retErr error) {
 	defer I.recoveryImpl()
+	defer I.stats.observe(APIDetect, time.Now(), len(inputText), &retResults, &retErr)
 
-	if !I.hasConfigured() { // not configured
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if I.hasClosed() {
 		return nil, header.ErrProcessAfterClose
 	}
-	if len(inputText) > DefMaxInput {
-		return nil, fmt.Errorf("DefMaxInput: %d , %w", DefMaxInput, header.ErrMaxInputLimit)
+	if err := checkLimit("MaxInput", rs.limits.MaxInput, len(inputText)); err != nil {
+		return nil, err
+	}
+	retResults, retErr = I.detectImpl(ctx, rs.withOptions(opts), inputText)
+	return
+}
+
+// DetectStream detects sensitive information for a reader without input size limitation,
+// onResult is called for every result in order, a non-nil error returned by onResult stops detection
+// 对io.Reader进行流式敏感信息识别，不限制输入大小
+func (I *Engine) DetectStream(r io.Reader, onResult func(res *header.DetectResult) error,
+	opts ...header.DetectOption,
+) error {
+	return I.DetectStreamContext(context.Background(), r, onResult, opts...)
+}
+
+// DetectStreamContext is DetectStream with ctx, detection stops once ctx is done and ctx.Err() is returned
+// 带 context 的 DetectStream，ctx 结束后停止识别并返回 ctx.Err()
+func (I *Engine) DetectStreamContext(ctx context.Context, r io.Reader,
+	onResult func(res *header.DetectResult) error, opts ...header.DetectOption,
+) (retErr error) {
+	defer I.recoveryImpl()
+	cr := &countReader{Reader: r}
+	start := time.Now()
+	defer func() { I.stats.observe(APIDetectStream, start, cr.n, nil, &retErr) }()
+
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
+		panic(header.ErrHasNotConfigured)
+	}
+	if I.hasClosed() {
+		return header.ErrProcessAfterClose
 	}
-	retResults, retErr = I.detectImpl(inputText)
+	rs = rs.withOptions(opts)
+	retErr = I.detectStreamImpl(ctx, rs, cr, func(_ []byte, _ int, results []*header.DetectResult) error {
+		I.stats.addHits(results)
+		for _, res := range results {
+			if err := onResult(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	return
 }
 
 // DetectMap detects KV map
 // 对map[string]string进行敏感信息识别
-func (I *Engine) DetectMap(inputMap map[string]string) (retResults []*header.DetectResult, retErr error) {
+func (I *Engine) DetectMap(inputMap map[string]string, opts ...header.DetectOption) ([]*header.DetectResult, error) {
+	return I.DetectMapContext(context.Background(), inputMap, opts...)
+}
+
+// DetectMapContext is DetectMap with ctx, detection stops once ctx is done,
+// results found so far are returned with ctx.Err()
+// 带 context 的 DetectMap，ctx 结束后停止识别，返回已识别的结果和 ctx.Err()
+func (I *Engine) DetectMapContext(
+	ctx context.Context, inputMap map[string]string, opts ...header.DetectOption,
+) (retResults []*header.DetectResult, retErr error) {
 	defer I.recoveryImpl()
+	defer I.stats.observe(APIDetectMap, time.Now(), mapSize(inputMap), &retResults, &retErr)
 
-	if !I.hasConfigured() { // not configured
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if I.hasClosed() {
 		return nil, header.ErrProcessAfterClose
 	}
-	if len(inputMap) > DefMaxItem {
-		return nil, fmt.Errorf("DefMaxItem: %d , %w", DefMaxItem, header.ErrMaxInputLimit)
-	}
-	inMap := make(map[string]string)
-	for k, v := range inputMap {
-		loK := strings.ToLower(k)
-		inMap[loK] = v
+	if err := checkLimit("MaxItem", rs.limits.MaxItem, len(inputMap)); err != nil {
+		return nil, err
 	}
-	retResults, retErr = I.detectMapImpl(inMap)
+	// keys are passed to detectors as is, so that words of camelCase keys can be split for KDict
+	retResults, retErr = I.detectMapImpl(ctx, rs.withOptions(opts), inputMap)
+	lowerResultKeys(retResults)
 	return
 }
 
 // DetectJSON detects json string
 // 对json string 进行敏感信息识别
-func (I *Engine) DetectJSON(jsonText string) (retResults []*header.DetectResult, retErr error) {
+func (I *Engine) DetectJSON(jsonText string, opts ...header.DetectOption) ([]*header.DetectResult, error) {
+	return I.DetectJSONContext(context.Background(), jsonText, opts...)
+}
+
+// DetectJSONContext is DetectJSON with ctx, detection stops once ctx is done,
+// results found so far are returned with ctx.Err()
+// 带 context 的 DetectJSON，ctx 结束后停止识别，返回已识别的结果和 ctx.Err()
+func (I *Engine) DetectJSONContext(
+	ctx context.Context, jsonText string, opts ...header.DetectOption,
+) (retResults []*header.DetectResult, retErr error) {
 	defer I.recoveryImpl()
+	defer I.stats.observe(APIDetectJSON, time.Now(), len(jsonText), &retResults, &retErr)
 
-	if !I.hasConfigured() { // not configured
+	rs := I.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if I.hasClosed() {
 		return nil, header.ErrProcessAfterClose
 	}
-	retResults, _, retErr = I.detectJSONImpl(jsonText)
+	retResults, _, retErr = I.detectJSONImpl(ctx, rs.withOptions(opts), jsonText)
 	return
 }
 
+// RegisterVerifier registers a verification algorithm which can be used by name in Verify.VAlgo of config,
+// context is the window around res where CDict and CReg are checked, fn returns false to drop res.
+// It must be called before ApplyConfig* which uses it, fn must be safe for concurrent use.
+// 注册自定义校验函数，可以在配置的 Verify.VAlgo 中按名称使用，需要在 ApplyConfig* 之前调用
+func (I *Engine) RegisterVerifier(name string, fn func(res *header.DetectResult, context []byte) bool) error {
+	defer I.recoveryImpl()
+	if I.hasClosed() {
+		return header.ErrProcessAfterClose
+	}
+	if len(name) == 0 || fn == nil {
+		return header.ErrVerifierInvalid
+	}
+	if detector.IsBuiltinVerifyAlgo(name) {
+		return fmt.Errorf("%w, name: %s", header.ErrVerifierNameConflict, name)
+	}
+
+	I.mu.Lock()
+	defer I.mu.Unlock()
+	if _, ok := I.diyVerifierMap[name]; ok {
+		return fmt.Errorf("%w, name: %s", header.ErrVerifierNameConflict, name)
+	}
+	// detectors keep the old map, so a new one is created instead of modifying it
+	verifiers := make(map[string]detector.VerifyFunc, len(I.diyVerifierMap)+1)
+	for k, v := range I.diyVerifierMap {
+		verifiers[k] = v
+	}
+	verifiers[name] = fn
+	I.diyVerifierMap = verifiers
+	return nil
+}
+
+// RegisterDetector registers a custom detector under its RuleID, it works like a rule of config in Detect*,
+// DeIdentify* and stats, results are masked by MaskRules.RuleName of GetMaskRuleName().
+// It survives ApplyConfig*, RuleID must not be used by Rules of config or another registered detector.
+// Engine APIs of header do not include it, use WithDetectors in NewEngineWithOptions instead.
+// 注册自定义识别器，与配置中的规则一样参与识别和打码，RuleID 不能与配置中的规则重复
+func (I *Engine) RegisterDetector(obj detector.API) error {
+	defer I.recoveryImpl()
+	if I.hasClosed() {
+		return header.ErrProcessAfterClose
+	}
+	if obj == nil {
+		return header.ErrDetectorInvalid
+	}
+
+	I.mu.Lock()
+	defer I.mu.Unlock()
+	ruleID := obj.GetRuleID()
+	if _, ok := I.diyDetectorMap[ruleID]; ok {
+		return fmt.Errorf("%w, RuleID: %d", header.ErrDetectorConflict, ruleID)
+	}
+	rs := I.currentRuleSet()
+	if rs != nil {
+		if _, ok := rs.ruleItemMap[ruleID]; ok {
+			return fmt.Errorf("%w, RuleID: %d", header.ErrDetectorConflict, ruleID)
+		}
+	}
+	I.setupDetector(obj)
+	I.diyDetectorMap[ruleID] = obj
+	if rs != nil && rs.confObj != nil { // configured, the new detector is used by following calls
+		newRs := rs.clone()
+		newRs.detectorMap[ruleID] = obj
+		I.storeRuleSet(newRs)
+	}
+	return nil
+}
+
 // private func
 
 // detectImpl works for the Detect API
-func (I *Engine) detectImpl(inputText string) ([]*header.DetectResult, error) {
+func (I *Engine) detectImpl(ctx context.Context, rs *ruleSet, inputText string) ([]*header.DetectResult, error) {
+	var results []*header.DetectResult
+	var err error
+	if rs.isParallel(len(inputText)) {
+		results, err = I.detectParallel(ctx, rs, inputText)
+	} else {
+		results, err = I.detectLines(ctx, rs, inputText, startTextPos)
+	}
+	if err != nil {
+		return results, err
+	}
+	return I.detectMultiLine(ctx, rs, inputText, startTextPos, results)
+}
+
+// detectLines detects inputText line by line, inputText starts at pos of the whole input
+func (I *Engine) detectLines(
+	ctx context.Context, rs *ruleSet, inputText string, pos textPos,
+) ([]*header.DetectResult, error) {
 	rd := bufio.NewReaderSize(strings.NewReader(inputText), DefLineBlockSize)
-	currPos := 0
 	results := make([]*header.DetectResult, 0, DefResultSize)
 
-	for {
+	for off := 0; ; {
 		line, err := rd.ReadBytes('\n')
 		if len(line) > 0 {
-			newLine := I.detectPre(line)
-			lineResults := I.detectProcess(newLine)
-			postResults := I.detectPost(lineResults, currPos)
-			results = append(results, postResults...)
-			currPos += len(newLine)
+			orig := inputText[off : off+len(line)]
+			results = append(results, I.detectLine(ctx, rs, line, orig, pos)...)
+			pos = pos.advance(orig)
+			off += len(line)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil { // results of the cancelled line may be partial
+			return results, ctxErr
 		}
 		if err != nil {
 			if err == io.EOF {
@@ -101,34 +258,179 @@ func (I *Engine) detectImpl(inputText string) ([]*header.DetectResult, error) {
 	return results, nil
 }
 
-// detectPre calls prepare func before detect
-func (I *Engine) detectPre(line []byte) []byte {
+// detectLine detects a line which starts at pos of the whole input, line will be modified by detectPre,
+// orig is the original content of line
+func (I *Engine) detectLine(
+	ctx context.Context, rs *ruleSet, line []byte, orig string, pos textPos,
+) []*header.DetectResult {
+	newLine, fm := I.detectPre(line)
+	lineResults := I.detectProcess(ctx, rs, newLine)
+	if rs.deobfuscate {
+		lineResults = I.detectObfuscated(ctx, rs, newLine, lineResults)
+	}
+	fm.mapResults(lineResults)
+	return I.detectPost(rs, lineResults, orig, pos)
+}
+
+// detectStreamImpl reads r line by line and calls emit with every committed chunk of input and its results.
+// Lines longer than DefStreamChunkSize are split into chunks, each chunk is detected together with the next
+// DefStreamOverlap bytes, so results which straddle a chunk boundary are still found.
+// If there are MultiLine rules, lines are collected into a chunk of about DefStreamChunkSize, which is detected
+// together with the next DefMultiLineWindow bytes of lines, so MultiLine rules see a sliding window of lines.
+// Memory is bounded by about 2*DefStreamChunkSize+DefStreamOverlap+DefMultiLineWindow no matter how large
+// the input is.
+func (I *Engine) detectStreamImpl(ctx context.Context, rs *ruleSet, r io.Reader,
+	emit func(chunk []byte, chunkPos int, results []*header.DetectResult) error,
+) error {
+	rd := bufio.NewReaderSize(r, DefStreamChunkSize)
+	pending := make([]byte, 0, DefStreamChunkSize)
+	pendingPos := startTextPos // absolute position of pending[0]
+	multiLine := len(I.multiLineDetectors(rs)) > 0
+	for {
+		part, err := rd.ReadSlice('\n')
+		pending = append(pending, part...)
+		lineDone := !errors.Is(err, bufio.ErrBufferFull)
+		if multiLine && err == nil && len(pending) < DefStreamChunkSize+DefMultiLineWindow {
+			continue // read the following lines for MultiLine rules
+		}
+		if len(pending) > 0 && (lineDone || len(pending) >= DefStreamChunkSize+DefStreamOverlap) {
+			cut := len(pending)
+			if !lineDone {
+				cut -= DefStreamOverlap
+				for cut > 0 && !utf8.RuneStart(pending[cut]) {
+					cut--
+				}
+			} else if multiLine && err == nil {
+				// lines in the last DefMultiLineWindow bytes are detected again with the next chunk
+				if idx := bytes.LastIndexByte(pending[:cut-DefMultiLineWindow], '\n'); idx != -1 {
+					cut = idx + 1
+				}
+			}
+			n, emitErr := I.detectStreamChunk(ctx, rs, pending, pendingPos, cut, emit)
+			if emitErr != nil {
+				return emitErr
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			pendingPos = pendingPos.advance(B2S(pending[:n]))
+			pending = append(pending[:0], pending[n:]...)
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// detectStreamChunk detects pending, results starting before cut are emitted with pending[:n],
+// n is cut or the end of the last emitted result, the rest will be detected again with the next chunk
+func (I *Engine) detectStreamChunk(ctx context.Context, rs *ruleSet, pending []byte, pendingPos textPos, cut int,
+	emit func(chunk []byte, chunkPos int, results []*header.DetectResult) error,
+) (int, error) {
+	// detectLines and detectMultiLine detect copies of pending, pending keeps the original bytes for output
+	results, _ := I.detectLines(ctx, rs, B2S(pending), pendingPos)
+	results, _ = I.detectMultiLine(ctx, rs, B2S(pending), pendingPos, results)
+	n := cut
+	committed := results[:0]
+	for _, res := range results {
+		if res.ByteStart-pendingPos.byteOffset >= cut { // will be found again in the next chunk
+			continue
+		}
+		if end := res.ByteEnd - pendingPos.byteOffset; end > n {
+			n = end
+		}
+		committed = append(committed, res)
+	}
+	return n, emit(pending[:n], pendingPos.byteOffset, committed)
+}
+
+// detectMultiLine detects inputText which starts at pos of the whole input by MultiLine rules,
+// results are merged with lineResults which have been found in inputText line by line
+func (I *Engine) detectMultiLine(ctx context.Context, rs *ruleSet, inputText string, pos textPos,
+	lineResults []*header.DetectResult,
+) ([]*header.DetectResult, error) {
+	objs := I.multiLineDetectors(rs)
+	if len(objs) == 0 {
+		return lineResults, nil
+	}
+	text, fm := I.detectPre([]byte(inputText))
+	results := make([]*header.DetectResult, 0, DefResultSize)
+	for _, obj := range objs {
+		if ctxErr := ctx.Err(); ctxErr != nil { // cancelled between rules
+			return lineResults, ctxErr
+		}
+		res, _ := obj.DetectBytes(text)
+		results = append(results, res...)
+	}
+	if len(results) == 0 {
+		return lineResults, nil
+	}
+	fm.mapResults(results)
+	sort.Sort(ResultList(results)) // aJustResultPos counts positions in one pass for sorted results
+	results = I.detectPost(rs, rs.filterScore(results), inputText, pos)
+	return I.mergeResults(rs, lineResults, results), nil
+}
+
+// multiLineDetectors returns VALUE detectors of MultiLine rules in order of RuleID
+func (I *Engine) multiLineDetectors(rs *ruleSet) []detector.API {
+	var objs []detector.API
+	for _, obj := range rs.detectorMap {
+		if obj == nil || !isMultiLine(obj) {
+			continue
+		}
+		if I.isOnlyForLog() && obj.GetRuleID() > rs.limits.MaxRegexRuleID && obj.UseRegex() {
+			continue // same as detectBytes in log processor mod
+		}
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].GetRuleID() < objs[j].GetRuleID() })
+	return objs
+}
+
+// isMultiLine checks whether obj is detected on the whole input instead of line by line
+func isMultiLine(obj detector.API) bool {
+	m, ok := obj.(interface{ IsMultiLine() bool })
+	return ok && m.IsMultiLine()
+}
+
+// detectPre calls prepare func before detect, positions of results in the returned line are mapped back to
+// positions in line by foldMap.mapResults
+func (I *Engine) detectPre(line []byte) ([]byte, *foldMap) {
 	line = I.unquoteEscapeChar(line)
 	line = I.replaceWideChar(line)
-	return line
+	return foldText(line)
 }
 
 // detectProcess detects sensitive info for a line
-func (I *Engine) detectProcess(line []byte) []*header.DetectResult {
+func (I *Engine) detectProcess(ctx context.Context, rs *ruleSet, line []byte) []*header.DetectResult {
 	// detect from a byte array
-	bytesResults, _ := I.detectBytes(line)
+	bytesResults, _ := I.detectBytes(ctx, rs, line)
 	// detect from a kvList which is extracted from the byte array
 	// kvList is used for the two item with same key
 	kvList := I.extractKVList(line)
-	kvResults, _ := I.detectKVList(kvList)
-	results := I.mergeResults(bytesResults, kvResults)
+	kvResults, _ := I.detectKVList(ctx, rs, kvList)
+	results := I.mergeResults(rs, rs.filterScore(bytesResults), rs.filterScore(kvResults))
 	return results
 }
 
 // detectBytes detects for a line
-func (I *Engine) detectBytes(line []byte) ([]*header.DetectResult, error) {
+func (I *Engine) detectBytes(ctx context.Context, rs *ruleSet, line []byte) ([]*header.DetectResult, error) {
+	if rs.isParallel(len(line)) {
+		return I.detectBytesParallel(ctx, rs, line)
+	}
 	results := make([]*header.DetectResult, 0, DefResultSize)
 	var retErr error
 	// start := time.Now()
-	for _, obj := range I.detectorMap {
-		if obj != nil && obj.IsValue() {
+	for _, obj := range rs.detectorMap {
+		if ctxErr := ctx.Err(); ctxErr != nil { // cancelled between rules
+			return results, ctxErr
+		}
+		if obj != nil && obj.IsValue() && !isMultiLine(obj) { // MultiLine rules are used by detectMultiLine
 			if I.isOnlyForLog() { // used in log processor mod, need very efficient
-				if obj.GetRuleID() > DefMaxRegexRuleID && obj.UseRegex() { // if ID>MAX and rule uses regex
+				if obj.GetRuleID() > rs.limits.MaxRegexRuleID && obj.UseRegex() { // if ID>MAX and rule uses regex
 					continue // will not use this rule in log processor mod
 				}
 			}
@@ -140,7 +442,7 @@ func (I *Engine) detectBytes(line []byte) ([]*header.DetectResult, error) {
 			results = append(results, res...)
 		}
 	}
-	// logger.Debugf("check rule:%d, len:%d, cast:%v\n", len(I.detectorMap), len(line), time.Since(start))
+	// logger.Debugf("check rule:%d, len:%d, cast:%v\n", len(rs.detectorMap), len(line), time.Since(start))
 
 	// the last error will be returned
 	return results, retErr
@@ -181,9 +483,8 @@ func (I *Engine) extractKVList(line []byte) []*detector.KVItem {
 			_ = kPos
 
 			if len(left) != 0 && len(right) != 0 {
-				loLeft := strings.ToLower(left)
 				kvList = append(kvList, &detector.KVItem{
-					Key:   loLeft,
+					Key:   left, // Key of results is lower case, see detectKVList
 					Value: right,
 					Start: vPos[0],
 					End:   vPos[1],
@@ -254,13 +555,18 @@ func lastToken(line []byte, offset int) (string, []int) {
 }
 
 // detectKVList accepts kvList to do detection
-func (I *Engine) detectKVList(kvList []*detector.KVItem) ([]*header.DetectResult, error) {
+func (I *Engine) detectKVList(
+	ctx context.Context, rs *ruleSet, kvList []*detector.KVItem,
+) ([]*header.DetectResult, error) {
 	results := make([]*header.DetectResult, 0, DefResultSize)
 
-	for _, obj := range I.detectorMap {
+	for _, obj := range rs.detectorMap {
+		if ctxErr := ctx.Err(); ctxErr != nil { // cancelled between rules
+			return results, ctxErr
+		}
 		if obj != nil && obj.IsKV() {
 			if I.isOnlyForLog() { // used in log processor mod, need very efficient
-				if obj.GetRuleID() > DefMaxRegexRuleID && obj.UseRegex() { // if ID>MAX and rule uses regex
+				if obj.GetRuleID() > rs.limits.MaxRegexRuleID && obj.UseRegex() { // if ID>MAX and rule uses regex
 					continue // will not use this rule in log processor mod
 				}
 			}
@@ -270,6 +576,7 @@ func (I *Engine) detectKVList(kvList []*detector.KVItem) ([]*header.DetectResult
 				// detectKVList is called from detect(), so result type will be VALUE
 				mapResults[i].ResultType = detector.ResultTypeValue
 			}
+			lowerResultKeys(mapResults)
 
 			results = append(results, mapResults...)
 		}
@@ -278,9 +585,11 @@ func (I *Engine) detectKVList(kvList []*detector.KVItem) ([]*header.DetectResult
 }
 
 // detectPost calls post func after detect
-func (I *Engine) detectPost(results []*header.DetectResult, currPos int) []*header.DetectResult {
-	ret := I.aJustResultPos(results, currPos)
-	ret = I.maskResults(ret)
+func (I *Engine) detectPost(
+	rs *ruleSet, results []*header.DetectResult, orig string, pos textPos,
+) []*header.DetectResult {
+	ret := I.aJustResultPos(results, orig, pos)
+	ret = I.maskResults(rs, ret)
 	return ret
 }
 
@@ -330,8 +639,9 @@ func (a ResultList) Equal(i, j int) bool {
 	return a[i].ByteStart == a[j].ByteStart && a[j].ByteEnd == a[i].ByteEnd && a[i].Key == a[j].Key
 }
 
-// merge and sort two detect results
-func (I *Engine) mergeResults(a []*header.DetectResult, b []*header.DetectResult) []*header.DetectResult {
+// merge and sort two detect results, overlapping results are resolved by Overlap of Global and rules
+func (I *Engine) mergeResults(rs *ruleSet, a []*header.DetectResult, b []*header.DetectResult,
+) []*header.DetectResult {
 	var total []*header.DetectResult
 	if len(a) == 0 {
 		total = b
@@ -361,20 +671,18 @@ func (I *Engine) mergeResults(a []*header.DetectResult, b []*header.DetectResult
 			continue
 		}
 
-		for j := i + 1; j < sz; j++ {
+		// results are sorted by ByteStart, the ones after total[i].ByteEnd do not overlap it
+		for j := i + 1; j < sz && total[j].ByteStart <= total[i].ByteEnd; j++ {
 			if !mark[j] {
 				continue
 			}
 
-			// inner element will be ignored
-			if ResultList(total).Equal(i, j) {
+			keepI, keepJ := rs.resolveOverlap(ResultList(total), i, j)
+			mark[j] = keepJ
+			if !keepI {
 				mark[i] = false
 				break
 			}
-
-			if ResultList(total).Contain(i, j) || ResultList(total).Contain(j, i) {
-				mark[j] = false
-			}
 		}
 	}
 	ret := make([]*header.DetectResult, 0, sz)
@@ -386,23 +694,159 @@ func (I *Engine) mergeResults(a []*header.DetectResult, b []*header.DetectResult
 	return ret
 }
 
-// aJustResultPos a just position offset
-func (I *Engine) aJustResultPos(results []*header.DetectResult, currPos int) []*header.DetectResult {
-	if currPos > 0 {
-		for i := range results {
-			results[i].ByteStart += currPos
-			results[i].ByteEnd += currPos
+// resolveOverlap decides whether list[i] and list[j] are kept, list[i] is sorted before list[j]
+func (rs *ruleSet) resolveOverlap(list ResultList, i, j int) (bool, bool) {
+	a, b := list[i], list[j]
+	policy := rs.overlapPolicy(a.RuleID, b.RuleID)
+	if len(policy) == 0 || policy == conf.OverlapContain {
+		// inner element will be ignored, of two results with the same ByteStart the shorter one is kept
+		if list.Equal(i, j) {
+			return false, true
+		}
+		if list.Contain(i, j) || list.Contain(j, i) {
+			return true, false
+		}
+		return true, true
+	}
+	// unlike CONTAIN, KV results in text are in the same input as the other results of text, results of
+	// DetectMap are in values of their keys
+	sameInput := a.Key == b.Key || a.ResultType == detector.ResultTypeValue && b.ResultType == detector.ResultTypeValue
+	overlapped := a.ByteStart < b.ByteEnd && b.ByteStart < a.ByteEnd || list.Equal(i, j)
+	if policy == conf.OverlapAll || !sameInput || !overlapped {
+		return true, true
+	}
+	var cmp int
+	switch policy {
+	case conf.OverlapLevel:
+		cmp = compareInt(levelValue(a.Level), levelValue(b.Level))
+	case conf.OverlapScore:
+		cmp = compareFloat(a.Score, b.Score)
+	case conf.OverlapPriority:
+		cmp = compareInt(int(rs.rulePriority(a.RuleID)), int(rs.rulePriority(b.RuleID)))
+	}
+	if cmp == 0 { // LONGEST, or a tie of the others
+		cmp = compareInt(a.ByteEnd-a.ByteStart, b.ByteEnd-b.ByteStart)
+	}
+	if cmp == 0 {
+		cmp = compareInt(int(a.RuleID), int(b.RuleID))
+	}
+	return cmp > 0, cmp <= 0
+}
+
+// overlapPolicy returns the policy for overlapping results of two rules, Overlap of a rule overrides the one of
+// Global, if both rules have different Overlap, the one of the rule with higher Priority, then higher RuleID is used
+func (rs *ruleSet) overlapPolicy(ruleA, ruleB int32) string {
+	pa, pb := rs.ruleOverlap(ruleA), rs.ruleOverlap(ruleB)
+	switch {
+	case len(pa) == 0 && len(pb) == 0:
+		return rs.overlap
+	case len(pb) == 0 || pa == pb:
+		return pa
+	case len(pa) == 0:
+		return pb
+	}
+	if prA, prB := rs.rulePriority(ruleA), rs.rulePriority(ruleB); prA > prB || (prA == prB && ruleA > ruleB) {
+		return pa
+	}
+	return pb
+}
+
+// ruleOverlap returns Overlap of the rule, empty for detectors of RegisterDetector
+func (rs *ruleSet) ruleOverlap(ruleID int32) string {
+	if item := rs.ruleItemMap[ruleID]; item != nil {
+		return item.Overlap
+	}
+	return ""
+}
+
+// rulePriority returns Priority of the rule, 0 for detectors of RegisterDetector
+func (rs *ruleSet) rulePriority(ruleID int32) int32 {
+	if item := rs.ruleItemMap[ruleID]; item != nil {
+		return item.Priority
+	}
+	return 0
+}
+
+// compareInt returns 1 if x > y, -1 if x < y, 0 if x == y
+func compareInt(x, y int) int {
+	switch {
+	case x > y:
+		return 1
+	case x < y:
+		return -1
+	}
+	return 0
+}
+
+// compareFloat returns 1 if x > y, -1 if x < y, 0 if x == y
+func compareFloat(x, y float64) int {
+	switch {
+	case x > y:
+		return 1
+	case x < y:
+		return -1
+	}
+	return 0
+}
+
+// textPos is a position in the whole input
+type textPos struct {
+	byteOffset int // offset in bytes
+	runeOffset int // offset in runes
+	line       int // 1-based line number
+	column     int // 1-based column in runes
+}
+
+// startTextPos is the position of the beginning of input
+var startTextPos = textPos{line: 1, column: 1}
+
+// advance returns the position after text which starts at p
+func (p textPos) advance(text string) textPos {
+	p.byteOffset += len(text)
+	p.runeOffset += utf8.RuneCountInString(text)
+	if idx := strings.LastIndexByte(text, '\n'); idx != -1 {
+		p.line += strings.Count(text, "\n")
+		p.column = 1 + utf8.RuneCountInString(text[idx+1:])
+	} else {
+		p.column += utf8.RuneCountInString(text)
+	}
+	return p
+}
+
+// aJustResultPos a just position offset, results are in orig which starts at pos of the whole input,
+// rune offsets, line and column are filled by the byte offsets in orig
+func (I *Engine) aJustResultPos(results []*header.DetectResult, orig string, pos textPos) []*header.DetectResult {
+	cur, curByte := pos, 0
+	for _, res := range results {
+		if res.ByteStart < 0 || res.ByteStart > res.ByteEnd || res.ByteEnd > len(orig) {
+			continue // out of orig, keep it as is
+		}
+		if res.ByteStart < curByte { // results are sorted in most cases, count from the beginning if not
+			cur, curByte = pos, 0
 		}
+		cur, curByte = cur.advance(orig[curByte:res.ByteStart]), res.ByteStart
+		res.RuneStart = cur.runeOffset
+		res.RuneEnd = cur.runeOffset + utf8.RuneCountInString(orig[res.ByteStart:res.ByteEnd])
+		res.Line, res.Column = cur.line, cur.column
+		res.ByteStart += pos.byteOffset
+		res.ByteEnd += pos.byteOffset
 	}
 	return results
 }
 
 // maskResults fill result.MaskText by calling mask.MaskResult()
-func (I *Engine) maskResults(results []*header.DetectResult) []*header.DetectResult {
+func (I *Engine) maskResults(rs *ruleSet, results []*header.DetectResult) []*header.DetectResult {
 	for _, res := range results {
-		if d, ok := I.detectorMap[res.RuleID]; ok {
+		if rs.skipMask {
+			res.MaskText = res.Text
+			continue
+		}
+		if d, ok := rs.detectorMap[res.RuleID]; ok {
 			maskRuleName := d.GetMaskRuleName()
-			if maskWorker, ok := I.maskerMap[maskRuleName]; ok {
+			if name, ok := rs.maskRuleMap[res.InfoType]; ok && rs.maskerMap[name] != nil {
+				maskRuleName = name
+			}
+			if maskWorker, ok := rs.maskerMap[maskRuleName]; ok {
 				_ = maskWorker.MaskResult(res)
 			} else { // Not Found
 				// logger.Errorf(fmt.Errorf("MaskRuleName: %s, Error: %w", maskRuleName, header.ErrMaskRuleNotfound).Error())
@@ -414,9 +858,16 @@ func (I *Engine) maskResults(results []*header.DetectResult) []*header.DetectRes
 }
 
 // detectMapImpl detect sensitive info for inputMap
-func (I *Engine) detectMapImpl(inputMap map[string]string) ([]*header.DetectResult, error) {
+func (I *Engine) detectMapImpl(
+	ctx context.Context, rs *ruleSet, inputMap map[string]string,
+) ([]*header.DetectResult, error) {
 	results := make([]*header.DetectResult, 0, DefResultSize)
-	for _, obj := range I.detectorMap {
+	var retErr error
+	inputMap = rs.filterPaths(inputMap)
+	for _, obj := range rs.detectorMap {
+		if retErr = ctx.Err(); retErr != nil { // cancelled between rules, partial results will be returned
+			break
+		}
 		if obj != nil {
 			res, err := obj.DetectMap(inputMap)
 			if err == nil {
@@ -427,10 +878,13 @@ func (I *Engine) detectMapImpl(inputMap map[string]string) ([]*header.DetectResu
 		}
 	}
 	// merge result to reduce combined item
-	results = I.mergeResults(results, nil)
-	results = I.maskResults(results)
+	results = I.mergeResults(rs, rs.filterScore(results), nil)
+	for i, res := range results { // positions of KV results are in the value of Key
+		I.aJustResultPos(results[i:i+1], inputMap[res.Key], startTextPos)
+	}
+	results = I.maskResults(rs, results)
 
-	return results, nil
+	return results, retErr
 }
 
 func getMin(x, y int) int {
@@ -448,7 +902,9 @@ func getMax(x, y int) int {
 }
 
 // detectJSONImpl implements detectJSON
-func (I *Engine) detectJSONImpl(jsonText string) (results []*header.DetectResult, kvMap map[string]string, err error) {
+func (I *Engine) detectJSONImpl(
+	ctx context.Context, rs *ruleSet, jsonText string,
+) (results []*header.DetectResult, kvMap map[string]string, err error) {
 	var jsonObj interface{}
 	err = json.Unmarshal([]byte(jsonText), &jsonObj)
 	if err != nil {
@@ -463,7 +919,7 @@ func (I *Engine) detectJSONImpl(jsonText string) (results []*header.DetectResult
 	// logger.Debugf("%+v\n", jsonObj)
 	kvMap = make(map[string]string)
 	I.dfsJSON("", &jsonObj, kvMap, false)
-	results, err = I.detectMapImpl(kvMap)
+	results, err = I.detectMapImpl(ctx, rs, kvMap)
 	for _, item := range results {
 		if orig, ok := kvMap[item.Key]; ok {
 			if out, err := I.deIdentifyByResult(orig, []*header.DetectResult{item}); err == nil {
@@ -471,7 +927,20 @@ func (I *Engine) detectJSONImpl(jsonText string) (results []*header.DetectResult
 			}
 		}
 	}
-	return
+	// paths of results and kvMap are lower case, dfsJSON looks up kvMap by lower case paths
+	lowerResultKeys(results)
+	loMap := make(map[string]string, len(kvMap))
+	for k, v := range kvMap {
+		loMap[strings.ToLower(k)] = v
+	}
+	return results, loMap, err
+}
+
+// lowerResultKeys converts Key of results into lower case
+func lowerResultKeys(results []*header.DetectResult) {
+	for _, res := range results {
+		res.Key = strings.ToLower(res.Key)
+	}
 }
 
 var wideCharMap = map[rune]string{
@@ -0,0 +1,122 @@
+// Package dlp sdk_fold.go implements Unicode folding of input before detection
+package dlp
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/laojianzi/godlp/header"
+)
+
+// foldMap maps byte offsets of folded text back to the text before folding
+type foldMap struct {
+	src     []byte // text before folding, it must not be modified
+	offsets []int  // offsets[i] is the offset in src of byte i of folded text
+}
+
+// private func
+
+// foldRune returns the folded rune of r, -1 means r is stripped, r itself means r is kept
+func foldRune(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E: // full-width ASCII variants, such as '１' and '＠'
+		return r - 0xFEE0
+	case r == 0x3000: // ideographic space
+		return ' '
+	case unicode.Is(unicode.Cf, r): // invisible format characters, such as zero-width space and joiner
+		return -1
+	}
+	return r
+}
+
+// foldText folds full-width ASCII variants into ASCII and ideographic spaces into spaces, and strips
+// invisible characters, the same as NFKC does for these characters, so full-width digits with a zero-width
+// space between them are detected as a PHONE. in is returned with a nil foldMap if nothing is folded,
+// otherwise in is kept as is and the folded text is a new slice.
+func foldText(in []byte) ([]byte, *foldMap) {
+	first := -1
+	for i := 0; i < len(in) && first == -1; {
+		if in[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, width := utf8.DecodeRune(in[i:])
+		if foldRune(r) != r {
+			first = i
+		}
+		i += width
+	}
+	if first == -1 { // fast path, nothing to fold
+		return in, nil
+	}
+
+	out := make([]byte, first, len(in))
+	copy(out, in[:first])
+	fm := &foldMap{src: in, offsets: make([]int, first, len(in))}
+	for i := range fm.offsets {
+		fm.offsets[i] = i
+	}
+	for i := first; i < len(in); {
+		if in[i] < utf8.RuneSelf {
+			out = append(out, in[i])
+			fm.offsets = append(fm.offsets, i)
+			i++
+			continue
+		}
+		r, width := utf8.DecodeRune(in[i:])
+		switch f := foldRune(r); {
+		case f == -1: // stripped
+		case f != r:
+			out = append(out, byte(f)) // folded runes are ASCII
+			fm.offsets = append(fm.offsets, i)
+		default:
+			out = append(out, in[i:i+width]...)
+			for k := i; k < i+width; k++ {
+				fm.offsets = append(fm.offsets, k)
+			}
+		}
+		i += width
+	}
+	return out, fm
+}
+
+// mapResults maps positions of results in folded text back to the text before folding,
+// invisible characters around a result are not included in its span
+func (fm *foldMap) mapResults(results []*header.DetectResult) {
+	if fm == nil {
+		return
+	}
+	for _, res := range results {
+		st, ed := res.ByteStart, res.ByteEnd
+		if st < 0 || st > ed || ed > len(fm.offsets) {
+			continue // out of folded text, keep it as is
+		}
+		if st == ed {
+			res.ByteStart = fm.offset(st)
+			res.ByteEnd = res.ByteStart
+			continue
+		}
+		res.ByteStart = fm.offsets[st]
+		res.ByteEnd = fm.endOf(ed - 1)
+	}
+}
+
+// offset returns the offset in src of byte i of folded text, the end of src for the end of folded text
+func (fm *foldMap) offset(i int) int {
+	if i < len(fm.offsets) {
+		return fm.offsets[i]
+	}
+	return len(fm.src)
+}
+
+// endOf returns the offset in src after byte i of folded text, a folded rune takes all of its bytes in src
+func (fm *foldMap) endOf(i int) int {
+	off := fm.offsets[i]
+	if fm.src[off] < utf8.RuneSelf {
+		return off + 1
+	}
+	if r, width := utf8.DecodeRune(fm.src[off:]); foldRune(r) != r {
+		return off + width
+	}
+	return off + 1 // a byte of a rune which is kept as is
+}
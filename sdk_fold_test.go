@@ -0,0 +1,41 @@
+package dlp_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEngine_DetectFold(t *testing.T) {
+	eng := newTestEngine(t)
+
+	// full-width digits with zero-width characters inside and around them
+	phone := "１８６\u200b１２３４\u200d１２３４"
+	inputText := "我的电话\u200b" + phone + "\u200b，谢谢"
+	results, err := eng.Detect(inputText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Detect() got %d results, want 1", len(results))
+	}
+	res := results[0]
+	if res.Text != "18612341234" {
+		t.Errorf("Detect() got Text = %q, want folded 18612341234", res.Text)
+	}
+	wantStart := strings.Index(inputText, phone)
+	if res.ByteStart != wantStart || res.ByteEnd != wantStart+len(phone) {
+		t.Errorf("Detect() got span [%d, %d), want [%d, %d)", res.ByteStart, res.ByteEnd, wantStart,
+			wantStart+len(phone))
+	}
+	if res.RuneStart != 5 || res.RuneEnd != 5+len([]rune(phone)) {
+		t.Errorf("Detect() got runes [%d, %d), want [5, %d)", res.RuneStart, res.RuneEnd, 5+len([]rune(phone)))
+	}
+
+	out, _, err := eng.DeIdentify(inputText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "我的电话\u200b186******34\u200b，谢谢"; out != want {
+		t.Errorf("DeIdentify() got = %q, want = %q", out, want)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"runtime/debug"
 	"strings"
+	"sync/atomic"
 
+	"github.com/laojianzi/godlp/conf"
 	"github.com/laojianzi/godlp/detector"
 	"github.com/laojianzi/godlp/header"
 	"github.com/laojianzi/godlp/internal/json"
@@ -59,48 +61,32 @@ func isCriticalPanic(r error) bool {
 
 // hasClosed check whether the engine has been closed
 func (I *Engine) hasClosed() bool {
-	return I.isClosed
+	return atomic.LoadInt32(&I.isClosed) == 1
 }
 
 func (I *Engine) isOnlyForLog() bool {
-	return I.isForLog
+	return atomic.LoadInt32(&I.isForLog) == 1
 }
 
-// hasConfigured check whether the engine has been configured
-func (I *Engine) hasConfigured() bool {
-	return I.isConfigured
-}
-
-// postLoadConfig will load config object
-func (I *Engine) postLoadConfig() error {
-	if I.confObj.Global.MaxLogInput > 0 {
-		DefMaxLogInput = I.confObj.Global.MaxLogInput
-	}
-	if I.confObj.Global.MaxRegexRuleID > 0 {
-		DefMaxRegexRuleID = I.confObj.Global.MaxRegexRuleID
-	}
-	if err := I.initLogger(); err != nil {
-		return err
+// postLoadConfig will load config object into a new rule set, caller must hold I.mu
+func (I *Engine) postLoadConfig(confObj *conf.DlpConf) (*ruleSet, error) {
+	rs := newRuleSet(confObj, I.defLimits, I.limits)
+	if err := I.initLogger(rs); err != nil {
+		return nil, err
 	}
-	if err := I.loadDetector(); err != nil {
-		return err
+	if err := I.loadDetector(rs); err != nil {
+		return nil, err
 	}
-	if err := I.loadMaskWorker(); err != nil {
-		return err
+	if err := I.loadMaskWorker(rs); err != nil {
+		return nil, err
 	}
-	I.isConfigured = true
-	return nil
-}
-
-// isDebugMode checks if DLP is in debug mode
-func (I *Engine) isDebugMode() bool {
-	return strings.Compare(strings.ToLower(I.confObj.Global.Mode), "debug") == 0
+	return rs, nil
 }
 
 // initLogger inits logger obj, in debug mode, log message will be printed in console and log file,
 // in release mode, log level is ERROR and log message will be printed into stderr
-func (I *Engine) initLogger() error {
-	if I.isDebugMode() {
+func (I *Engine) initLogger(rs *ruleSet) error {
+	if rs.isDebugMode() {
 		logger.SetLevel(logger.LevelDebug)
 		logger.Debugf("DLP@%s run in debug mode", I.Version)
 	} else { // release mode
@@ -110,30 +96,30 @@ func (I *Engine) initLogger() error {
 }
 
 // loadDetector loads detectors from config
-func (I *Engine) loadDetector() error {
+func (I *Engine) loadDetector(rs *ruleSet) error {
 	// fill detectorMap
-	if err := I.fillDetectorMap(); err != nil {
+	if err := I.fillDetectorMap(rs); err != nil {
 		return err
 	}
 	// disable rules
-	return I.disableRulesImpl(I.confObj.Global.DisableRules)
+	return I.disableRulesImpl(rs, rs.confObj.Global.DisableRules)
 }
 
-// loadMaskWorker loads mask worker from config
-func (I *Engine) loadMaskWorker() error {
-	maskRuleList := I.confObj.MaskRules
+// loadMaskWorker loads mask worker from config, maskers of RegisterMasker are kept
+func (I *Engine) loadMaskWorker(rs *ruleSet) error {
+	maskRuleList := rs.confObj.MaskRules
 
-	if I.maskerMap == nil {
-		I.maskerMap = make(map[string]mask.API)
+	for name, worker := range I.diyMaskerMap {
+		rs.maskerMap[name] = worker
 	}
 
 	for _, rule := range maskRuleList {
 		if obj, err := mask.NewWorker(rule, I); err == nil {
 			ruleName := obj.GetRuleName()
-			if old, ok := I.maskerMap[ruleName]; ok {
+			if old, ok := rs.maskerMap[ruleName]; ok {
 				logger.Errorf("ruleName: %s, error: %s", old.GetRuleName(), header.ErrLoadMaskNameConflict.Error())
 			} else {
-				I.maskerMap[ruleName] = obj
+				rs.maskerMap[ruleName] = obj
 			}
 		}
 	}
@@ -142,9 +128,9 @@ func (I *Engine) loadMaskWorker() error {
 
 // dfsJSON walk a json object, used for DetectJSON and DeIdentifyJSON
 // in DetectJSON(), isDeIdentify is false, kvMap is written only, will store json object path and value
-// in DeIdentifyJSON(), isDeIdentify is true, kvMap is read only, will store path and MaskText of sensitive information
+// in DeIdentifyJSON(), isDeIdentify is true, kvMap is read only, will store lower case path and MaskText
+// of sensitive information
 func (I *Engine) dfsJSON(path string, ptr *interface{}, kvMap map[string]string, isDeIdentify bool) interface{} {
-	path = strings.ToLower(path)
 
 	switch iv := (*ptr).(type) {
 	case map[string]interface{}:
@@ -180,7 +166,7 @@ func (I *Engine) dfsJSON(path string, ptr *interface{}, kvMap map[string]string,
 
 		// plain text
 		if isDeIdentify {
-			if kvMask, ok := kvMap[path]; ok {
+			if kvMask, ok := kvMap[strings.ToLower(path)]; ok {
 				return kvMask
 			}
 
@@ -206,18 +192,21 @@ func (I *Engine) selectRulesForLog() error {
 	return nil
 }
 
-func (I *Engine) fillDetectorMap() error {
-	ruleList := I.confObj.Rules
+func (I *Engine) fillDetectorMap(rs *ruleSet) error {
+	ruleList := rs.confObj.Rules
 
-	if I.detectorMap == nil {
-		I.detectorMap = make(map[int32]detector.API)
-	}
-	enableRules := I.confObj.Global.EnableRules
+	enableRules := rs.confObj.Global.EnableRules
 	fullSet := map[int32]bool{}
-	for _, rule := range ruleList {
+	for i, rule := range ruleList {
+		if _, ok := I.diyDetectorMap[rule.RuleID]; ok {
+			logger.Errorf("RuleID: %d, error: %s", rule.RuleID, header.ErrDetectorConflict.Error())
+			continue
+		}
 		if obj, err := detector.NewDetector(rule); err == nil {
+			I.setupDetector(obj)
 			ruleID := obj.GetRuleID()
-			I.detectorMap[ruleID] = obj
+			rs.detectorMap[ruleID] = obj
+			rs.ruleItemMap[ruleID] = &ruleList[i]
 			fullSet[ruleID] = false
 		} else {
 			logger.Errorf(err.Error())
@@ -227,43 +216,69 @@ func (I *Engine) fillDetectorMap() error {
 	// else only some rules are enabled.
 	if len(enableRules) > 0 {
 		for _, ruleID := range enableRules {
-			if _, ok := I.detectorMap[ruleID]; ok {
+			if _, ok := rs.detectorMap[ruleID]; ok {
 				fullSet[ruleID] = true
 			}
 		}
 		for k, v := range fullSet {
 			if !v {
-				I.detectorMap[k] = nil
+				rs.detectorMap[k] = nil
 			}
 		}
 	}
+	// detectors of RegisterDetector are not in Rules, so EnableRules and DisableRules do not apply to them
+	for ruleID, obj := range I.diyDetectorMap {
+		rs.detectorMap[ruleID] = obj
+	}
 	return nil
 }
 
+// setupDetector passes the stats counter and registered verifiers to obj if it accepts them
+func (I *Engine) setupDetector(obj detector.API) {
+	if c, ok := obj.(interface{ SetCounter(detector.Counter) }); ok && I.stats != nil {
+		c.SetCounter(I.stats)
+	}
+	if v, ok := obj.(interface {
+		SetVerifiers(map[string]detector.VerifyFunc)
+	}); ok && len(I.diyVerifierMap) > 0 {
+		v.SetVerifiers(I.diyVerifierMap)
+	}
+}
+
 // disableRules will disable rules based on ruleList, pass them all
 // 禁用规则，原子操作，每次禁用是独立操作，不会有历史依赖
 //
 // nolint: unused
 func (I *Engine) applyDisableRules(ruleList []int32) {
-	I.confObj.Global.DisableRules = ruleList
-	_ = I.loadDetector()
+	I.mu.Lock()
+	defer I.mu.Unlock()
+
+	rs := I.currentRuleSet()
+	if rs == nil || rs.confObj == nil {
+		return
+	}
+	confObj := *rs.confObj
+	confObj.Global.DisableRules = ruleList
+	if newRs, err := I.postLoadConfig(&confObj); err == nil {
+		I.storeRuleSet(newRs)
+	}
 }
 
-func (I *Engine) disableRulesImpl(ruleList []int32) error {
+func (I *Engine) disableRulesImpl(rs *ruleSet, ruleList []int32) error {
 	for _, ruleID := range ruleList {
-		if _, ok := I.detectorMap[ruleID]; ok {
-			I.detectorMap[ruleID] = nil
+		if _, ok := rs.detectorMap[ruleID]; ok {
+			rs.detectorMap[ruleID] = nil
 		}
 	}
 	total := 0
-	for k, rule := range I.detectorMap {
+	for k, rule := range rs.detectorMap {
 		if rule != nil {
 			total++
 		} else {
-			delete(I.detectorMap, k)
+			delete(rs.detectorMap, k)
 		}
 	}
-	if I.isDebugMode() {
+	if rs.isDebugMode() {
 		logger.Debugf("Total %d Rule loaded", total)
 	}
 	return nil
@@ -4,6 +4,7 @@ package dlp
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/laojianzi/godlp/header"
 	"github.com/laojianzi/godlp/mask"
@@ -12,16 +13,18 @@ import (
 // Mask will return masked text directly based on methodName
 func (e *Engine) Mask(inputText string, methodName string) (outputText string, err error) {
 	defer e.recoveryImpl()
-	if !e.hasConfigured() { // not configured
+	defer e.stats.observe(APIMask, time.Now(), len(inputText), nil, &err)
+	rs := e.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if e.hasClosed() {
 		return "", header.ErrProcessAfterClose
 	}
-	if len(inputText) > DefMaxInput {
-		return inputText, fmt.Errorf("DefMaxInput: %d , %w", DefMaxInput, header.ErrMaxInputLimit)
+	if err := checkLimit("MaxInput", rs.limits.MaxInput, len(inputText)); err != nil {
+		return inputText, err
 	}
-	if maskWorker, ok := e.maskerMap[methodName]; ok {
+	if maskWorker, ok := rs.maskerMap[methodName]; ok {
 		return maskWorker.Mask(inputText)
 	} else {
 		return inputText, fmt.Errorf("methodName: %s, error: %w", methodName, header.ErrMaskWorkerNotfound)
@@ -32,11 +35,13 @@ func (e *Engine) Mask(inputText string, methodName string) (outputText string, e
 // 根据tag mask里定义的脱敏规则对struct object直接脱敏, 会修改obj本身，传入指针，返回指针
 func (e *Engine) MaskStruct(inPtr interface{}) (outPtr interface{}, retErr error) {
 	defer e.recoveryImpl()
+	defer e.stats.observe(APIMaskStruct, time.Now(), 0, nil, &retErr)
 
 	outPtr = inPtr                      // fail back to inPtr
 	retErr = header.ErrMaskStructOutput // default return err if panic
 
-	if !e.hasConfigured() { // not configured
+	rs := e.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 
@@ -48,7 +53,7 @@ func (e *Engine) MaskStruct(inPtr interface{}) (outPtr interface{}, retErr error
 		return nil, header.ErrMaskStructInput
 	}
 
-	outPtr, retErr = e.maskStructImpl(inPtr, DefMaxCallDeep)
+	outPtr, retErr = e.maskStructImpl(rs, inPtr, rs.limits.MaxCallDeep)
 	return
 }
 
@@ -56,17 +61,27 @@ func (e *Engine) MaskStruct(inPtr interface{}) (outPtr interface{}, retErr error
 // 注册自定义打码函数
 func (e *Engine) RegisterMasker(maskName string, maskFunc func(string) (string, error)) error {
 	defer e.recoveryImpl()
-	if !e.hasConfigured() { // not configured
+	rs := e.currentRuleSet()
+	if rs == nil { // not configured
 		panic(header.ErrHasNotConfigured)
 	}
 	if e.hasClosed() {
 		return header.ErrProcessAfterClose
 	}
-	if _, ok := e.maskerMap[maskName]; ok {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// reload under lock, rules may be swapped after the check above
+	rs = e.currentRuleSet()
+	if _, ok := rs.maskerMap[maskName]; ok {
 		return header.ErrMaskNameConflict
 	} else {
 		if worker, err := e.NewDIYMaskWorker(maskName, maskFunc); err == nil {
-			e.maskerMap[maskName] = worker
+			newRs := rs.clone()
+			newRs.maskerMap[maskName] = worker
+			e.diyMaskerMap[maskName] = worker
+			e.storeRuleSet(newRs)
 			return nil
 		} else {
 			return err
@@ -112,7 +127,7 @@ func (e *Engine) NewDIYMaskWorker(maskName string, maskFunc func(string) (string
 
 // maskStructImpl will mask a struct object by tag mask info
 // 根据tag mask里定义的脱敏规则对struct object直接脱敏, 会修改obj本身，传入指针，返回指针
-func (e *Engine) maskStructImpl(inPtr interface{}, level int) (interface{}, error) {
+func (e *Engine) maskStructImpl(rs *ruleSet, inPtr interface{}, level int) (interface{}, error) {
 	// logger.Errorf("[DLP] level:%d, maskStructImpl: %+v", level, inPtr)
 	if level <= 0 { // call deep check
 		// logger.Errorf("[DLP] !call deep loop detected!")
@@ -135,14 +150,14 @@ func (e *Engine) maskStructImpl(inPtr interface{}, level int) (interface{}, erro
 	}
 
 	sz := val.NumField()
-	if sz > DefMaxInput {
-		return inPtr, fmt.Errorf("DefMaxInput: %d , %w", DefMaxInput, header.ErrMaxInputLimit)
+	if err := checkLimit("MaxInput", rs.limits.MaxInput, sz); err != nil {
+		return inPtr, err
 	}
 
 	for i := 0; i < sz; i++ {
 		valField := val.Field(i)
 		typeField := val.Type().Field(i)
-		if err := e.maskStructField(valField, typeField, level); err != nil {
+		if err := e.maskStructField(rs, valField, typeField, level); err != nil {
 			return nil, err
 		}
 	}
@@ -151,7 +166,7 @@ func (e *Engine) maskStructImpl(inPtr interface{}, level int) (interface{}, erro
 }
 
 // maskStructField will mask a struct field by tag mask info
-func (e *Engine) maskStructField(valField reflect.Value, typeField reflect.StructField, level int) error {
+func (e *Engine) maskStructField(rs *ruleSet, valField reflect.Value, typeField reflect.StructField, level int) error {
 	methodName, ok := typeField.Tag.Lookup("mask")
 	if !ok { // mask tag not found
 		return nil
@@ -163,27 +178,27 @@ func (e *Engine) maskStructField(valField reflect.Value, typeField reflect.Struc
 
 	switch valField.Kind() {
 	case reflect.String:
-		return e.maskTypeString(methodName, valField)
+		return e.maskTypeString(rs, methodName, valField)
 	case reflect.Struct:
-		return e.maskTypeStruct(valField, level)
+		return e.maskTypeStruct(rs, valField, level)
 	case reflect.Ptr:
-		return e.maskTypePtr(valField, level)
+		return e.maskTypePtr(rs, valField, level)
 	case reflect.Interface:
-		return e.maskTypeInterface(methodName, valField)
+		return e.maskTypeInterface(rs, methodName, valField)
 	case reflect.Slice, reflect.Array:
-		return e.maskTypeList(methodName, valField, level)
+		return e.maskTypeList(rs, methodName, valField, level)
 	default:
 	}
 
 	return nil
 }
 
-func (e *Engine) maskTypeString(methodName string, valField reflect.Value) error {
+func (e *Engine) maskTypeString(rs *ruleSet, methodName string, valField reflect.Value) error {
 	if len(methodName) <= 0 {
 		return nil
 	}
 
-	if maskWorker, ok := e.maskerMap[methodName]; ok {
+	if maskWorker, ok := rs.maskerMap[methodName]; ok {
 		if masked, err := maskWorker.Mask(valField.String()); err == nil {
 			if valField.CanSet() {
 				valField.SetString(masked)
@@ -194,10 +209,10 @@ func (e *Engine) maskTypeString(methodName string, valField reflect.Value) error
 	return nil
 }
 
-func (e *Engine) maskTypeStruct(valField reflect.Value, level int) error {
+func (e *Engine) maskTypeStruct(rs *ruleSet, valField reflect.Value, level int) error {
 	if valField.CanAddr() {
 		// logger.Errorf("[DLP] Struct, %s", typeField.Name)
-		_, err := e.maskStructImpl(valField.Addr().Interface(), level-1)
+		_, err := e.maskStructImpl(rs, valField.Addr().Interface(), level-1)
 		if err != nil {
 			return err
 		}
@@ -206,10 +221,10 @@ func (e *Engine) maskTypeStruct(valField reflect.Value, level int) error {
 	return nil
 }
 
-func (e *Engine) maskTypePtr(valField reflect.Value, level int) error {
+func (e *Engine) maskTypePtr(rs *ruleSet, valField reflect.Value, level int) error {
 	if !valField.IsNil() {
 		// logger.Errorf("[DLP] Ptr, %s", typeField.Name)
-		_, err := e.maskStructImpl(valField.Interface(), level-1)
+		_, err := e.maskStructImpl(rs, valField.Interface(), level-1)
 		if err != nil {
 			return err
 		}
@@ -218,7 +233,7 @@ func (e *Engine) maskTypePtr(valField reflect.Value, level int) error {
 	return nil
 }
 
-func (e *Engine) maskTypeInterface(methodName string, valField reflect.Value) error {
+func (e *Engine) maskTypeInterface(rs *ruleSet, methodName string, valField reflect.Value) error {
 	if !valField.CanInterface() {
 		return nil
 	}
@@ -229,7 +244,7 @@ func (e *Engine) maskTypeInterface(methodName string, valField reflect.Value) er
 		return nil
 	}
 
-	if maskWorker, ok := e.maskerMap[methodName]; ok {
+	if maskWorker, ok := rs.maskerMap[methodName]; ok {
 		if masked, err := maskWorker.Mask(inStr); err == nil {
 			if valField.CanSet() {
 				valField.Set(reflect.ValueOf(masked))
@@ -240,23 +255,23 @@ func (e *Engine) maskTypeInterface(methodName string, valField reflect.Value) er
 	return nil
 }
 
-func (e *Engine) maskTypeList(methodName string, valField reflect.Value, level int) error {
+func (e *Engine) maskTypeList(rs *ruleSet, methodName string, valField reflect.Value, level int) error {
 	length := valField.Len()
 	for j := 0; j < length; j++ {
 		item := valField.Index(j)
 		switch item.Kind() {
 		case reflect.String:
-			if err := e.maskTypeString(methodName, item); err != nil {
+			if err := e.maskTypeString(rs, methodName, item); err != nil {
 				return err
 			}
 		case reflect.Ptr:
-			if err := e.maskTypePtr(item, level); err != nil {
+			if err := e.maskTypePtr(rs, item, level); err != nil {
 				return err
 			}
 		case reflect.Struct:
 			if item.CanAddr() {
 				// logger.Errorf("[DLP] Struct, %s", item.Type().Name())
-				_, err := e.maskStructImpl(item.Addr().Interface(), level-1)
+				_, err := e.maskStructImpl(rs, item.Addr().Interface(), level-1)
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,180 @@
+// Package dlp sdk_option.go implements per-engine options of NewEngineWithOptions
+package dlp
+
+import (
+	"runtime"
+
+	"github.com/laojianzi/godlp/conf"
+	"github.com/laojianzi/godlp/detector"
+	"github.com/laojianzi/godlp/header"
+)
+
+// LimitOff set as MaxRegexRuleID or ParallelInput of Limits sets them to 0 explicitly, it overrides Global of
+// the config, so log processor uses no regex rule or input is never detected in parallel
+const LimitOff = conf.LimitOff
+
+// Limits are the limitations of an Engine, a limit <= 0 is taken from Global of the config,
+// and then from DefLimits if it is not set in the config either.
+// 0 of MaxRegexRuleID and ParallelInput is a valid value, use LimitOff to set it.
+type Limits struct {
+	MaxInput       int   // max input length of Detect, DeIdentify and Mask
+	MaxItem        int   // max input items of DetectMap and DeIdentifyMap
+	MaxCallDeep    int   // max call depth of MaskStruct
+	MaxLogInput    int   // max input length of log processor, longer log is cut
+	MaxLogItem     int   // max kv items of log processor, more items are cut
+	MaxRegexRuleID int32 // rules with ID > MaxRegexRuleID and using regex are skipped in log processor
+	ParallelInput  int   // input longer than ParallelInput is detected in parallel, 0 after merging means never
+	MaxWorkers     int   // max goroutines of a parallel call, default is runtime.GOMAXPROCS(0)
+}
+
+// DefLimits returns the default limits of DefMaxLogInput, DefMaxRegexRuleID and the other default values,
+// an engine copies them when it is created
+func DefLimits() Limits {
+	return Limits{
+		MaxInput:       DefMaxInput,
+		MaxItem:        DefMaxItem,
+		MaxCallDeep:    DefMaxCallDeep,
+		MaxLogInput:    int(DefMaxLogInput),
+		MaxLogItem:     DefMaxLogItem,
+		MaxRegexRuleID: DefMaxRegexRuleID,
+		MaxWorkers:     runtime.GOMAXPROCS(0),
+	}
+}
+
+// EngineOption configures an Engine in NewEngineWithOptions
+type EngineOption func(e *Engine)
+
+// WithLimits sets limits of the engine, limits <= 0 are not changed except LimitOff
+func WithLimits(limits Limits) EngineOption {
+	return func(e *Engine) {
+		e.limits = e.limits.merge(limits)
+	}
+}
+
+// WithMaxInput sets the max input length of Detect, DeIdentify and Mask
+func WithMaxInput(maxInput int) EngineOption {
+	return WithLimits(Limits{MaxInput: maxInput})
+}
+
+// WithMaxItem sets the max input items of DetectMap and DeIdentifyMap
+func WithMaxItem(maxItem int) EngineOption {
+	return WithLimits(Limits{MaxItem: maxItem})
+}
+
+// WithMaxCallDeep sets the max call depth of MaskStruct
+func WithMaxCallDeep(maxCallDeep int) EngineOption {
+	return WithLimits(Limits{MaxCallDeep: maxCallDeep})
+}
+
+// WithMaxLogInput sets the max input length of log processor
+func WithMaxLogInput(maxLogInput int) EngineOption {
+	return WithLimits(Limits{MaxLogInput: maxLogInput})
+}
+
+// WithMaxLogItem sets the max kv items of log processor
+func WithMaxLogItem(maxLogItem int) EngineOption {
+	return WithLimits(Limits{MaxLogItem: maxLogItem})
+}
+
+// WithMaxRegexRuleID sets the max ID of rules using regex in log processor, LimitOff means no regex rule
+func WithMaxRegexRuleID(ruleID int32) EngineOption {
+	return WithLimits(Limits{MaxRegexRuleID: ruleID})
+}
+
+// WithDetectors registers custom detectors under their RuleID, the same as RegisterDetector,
+// a later detector replaces the former one with the same RuleID
+func WithDetectors(objs ...detector.API) EngineOption {
+	return func(e *Engine) {
+		for _, obj := range objs {
+			if obj != nil {
+				e.diyDetectorMap[obj.GetRuleID()] = obj
+			}
+		}
+	}
+}
+
+// WithLatencyBounds sets upper bounds in seconds of latency histogram buckets of Stats,
+// bounds are copied, the default bounds are used if it is empty
+func WithLatencyBounds(bounds ...float64) EngineOption {
+	return func(e *Engine) {
+		e.latencyBounds = append([]float64(nil), bounds...)
+	}
+}
+
+// WithParallel enables parallel detection for input longer than parallelInput bytes,
+// it uses at most maxWorkers goroutines for a call, maxWorkers <= 0 means runtime.GOMAXPROCS(0),
+// parallelInput LimitOff disables parallel detection even if ParallelInput is set in the config
+func WithParallel(parallelInput int, maxWorkers int) EngineOption {
+	return WithLimits(Limits{ParallelInput: parallelInput, MaxWorkers: maxWorkers})
+}
+
+// private func
+
+// merge returns l overridden by the positive values of other, LimitOff of MaxRegexRuleID and ParallelInput
+// overrides l too, it is kept until resolveLimits
+func (l Limits) merge(other Limits) Limits {
+	if other.MaxInput > 0 {
+		l.MaxInput = other.MaxInput
+	}
+	if other.MaxItem > 0 {
+		l.MaxItem = other.MaxItem
+	}
+	if other.MaxCallDeep > 0 {
+		l.MaxCallDeep = other.MaxCallDeep
+	}
+	if other.MaxLogInput > 0 {
+		l.MaxLogInput = other.MaxLogInput
+	}
+	if other.MaxLogItem > 0 {
+		l.MaxLogItem = other.MaxLogItem
+	}
+	if other.MaxRegexRuleID != 0 {
+		l.MaxRegexRuleID = other.MaxRegexRuleID
+	}
+	if other.ParallelInput != 0 {
+		l.ParallelInput = other.ParallelInput
+	}
+	if other.MaxWorkers > 0 {
+		l.MaxWorkers = other.MaxWorkers
+	}
+	return l
+}
+
+// resolveLimits returns defLimits overridden by Global of confObj and then by engine limits
+func resolveLimits(confObj *conf.DlpConf, defLimits Limits, engineLimits Limits) Limits {
+	limits := defLimits
+	if confObj != nil {
+		limits = limits.merge(confLimits(confObj))
+	}
+	limits = limits.merge(engineLimits)
+	if limits.MaxRegexRuleID < 0 { // LimitOff
+		limits.MaxRegexRuleID = 0
+	}
+	if limits.ParallelInput < 0 {
+		limits.ParallelInput = 0
+	}
+	return limits
+}
+
+// confLimits returns limits in Global of confObj
+func confLimits(confObj *conf.DlpConf) Limits {
+	g := confObj.Global
+	return Limits{
+		MaxInput:       int(g.MaxInput),
+		MaxItem:        int(g.MaxItem),
+		MaxCallDeep:    int(g.MaxCallDeep),
+		MaxLogInput:    int(g.MaxLogInput),
+		MaxLogItem:     int(g.MaxLogItem),
+		MaxRegexRuleID: g.MaxRegexRuleID,
+		ParallelInput:  int(g.ParallelInput),
+		MaxWorkers:     int(g.MaxWorkers),
+	}
+}
+
+// checkLimit returns a *header.LimitError if size > max
+func checkLimit(name string, max int, size int) error {
+	if size > max {
+		return &header.LimitError{Limit: name, Max: max, Actual: size}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package dlp_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	dlp "github.com/laojianzi/godlp"
+	"github.com/laojianzi/godlp/header"
+)
+
+func TestEngine_Limits(t *testing.T) {
+	inputText := "18612341234是我的电话"
+
+	// limit of options
+	eng := newTestEngine(t, dlp.WithMaxInput(10))
+	_, err := eng.Detect(inputText)
+	var limitErr *header.LimitError
+	if !errors.Is(err, header.ErrMaxInputLimit) || !errors.As(err, &limitErr) {
+		t.Fatalf("Detect() got err = %v, want %v", err, header.ErrMaxInputLimit)
+	}
+	if limitErr.Limit != "MaxInput" || limitErr.Max != 10 || limitErr.Actual != len(inputText) {
+		t.Errorf("Detect() got %+v, want MaxInput: 10, actual: %d", limitErr, len(inputText))
+	}
+
+	// limit of config, another engine is not affected
+	other := newTestEngine(t)
+	if err = other.ApplyConfig(strings.Replace(other.GetDefaultConf(), "MaxLogInput: 4096",
+		"MaxLogInput: 4096\n  MaxItem: 1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	inputMap := map[string]string{"phone": "18612341234", "email": "abcd@abcd.com"}
+	if _, err = other.DetectMap(inputMap); !errors.As(err, &limitErr) || limitErr.Max != 1 || limitErr.Actual != 2 {
+		t.Errorf("DetectMap() got err = %v, want MaxItem: 1, actual: 2", err)
+	}
+	if _, err = eng.DetectMap(inputMap); err != nil {
+		t.Errorf("DetectMap() got err = %v, want nil", err)
+	}
+	if _, err = other.Detect(inputText); err != nil {
+		t.Errorf("Detect() got err = %v, want nil", err)
+	}
+
+	// LimitOff of options overrides MaxRegexRuleID of config, the regex rule of PHONE is skipped in log processor
+	regexConf := strings.Replace(other.GetDefaultConf(), "MaxRegexRuleID: 0", "MaxRegexRuleID: 1099", 1)
+	for _, tt := range []struct {
+		opts []dlp.EngineOption
+		want string
+	}{
+		{nil, "186******34是我的电话"},
+		{[]dlp.EngineOption{dlp.WithMaxRegexRuleID(dlp.LimitOff)}, inputText},
+	} {
+		logEng := newTestEngine(t, tt.opts...)
+		if err := logEng.ApplyConfig(regexConf); err != nil {
+			t.Fatal(err)
+		}
+		if out, _, _ := logEng.NewLogProcessor()(inputText); out != tt.want {
+			t.Errorf("NewLogProcessor() got = %q, want = %q", out, tt.want)
+		}
+	}
+
+	// defaults are copied when an engine is created, a later change does not affect it after ApplyConfig
+	copied := newTestEngine(t)
+	oldMaxRegexRuleID := dlp.DefMaxRegexRuleID
+	dlp.DefMaxRegexRuleID = 1099
+	defer func() { dlp.DefMaxRegexRuleID = oldMaxRegexRuleID }()
+	if err = copied.ApplyConfigDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if out, _, _ := copied.NewLogProcessor()(inputText); out != inputText {
+		t.Errorf("NewLogProcessor() got = %q, want = %q", out, inputText)
+	}
+
+	// LimitOff is accepted by config, the other negative limits are not
+	if err = other.ApplyConfig(strings.Replace(other.GetDefaultConf(), "MaxRegexRuleID: 0",
+		"MaxRegexRuleID: -1\n  ParallelInput: -1", 1)); err != nil {
+		t.Errorf("ApplyConfig() with LimitOff got err = %v, want nil", err)
+	}
+	if err = other.ApplyConfig(strings.Replace(other.GetDefaultConf(), "MaxRegexRuleID: 0",
+		"MaxRegexRuleID: -2", 1)); !errors.Is(err, header.ErrConfVerifyFailed) {
+		t.Errorf("ApplyConfig() with MaxRegexRuleID -2 got err = %v, want %v", err, header.ErrConfVerifyFailed)
+	}
+}
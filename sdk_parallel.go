@@ -0,0 +1,146 @@
+// Package dlp sdk_parallel.go implements parallel detection of large input on a bounded worker pool
+package dlp
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/laojianzi/godlp/detector"
+	"github.com/laojianzi/godlp/header"
+)
+
+// defParallelMinBlock is the min size of a block of input which is detected by a worker
+const defParallelMinBlock = 4 * 1024
+
+// private func
+
+// isParallel checks whether input of size should be detected in parallel
+func (rs *ruleSet) isParallel(size int) bool {
+	return rs.limits.ParallelInput > 0 && size > rs.limits.ParallelInput && rs.limits.MaxWorkers > 1
+}
+
+// sequential returns a view of rs which never detects in parallel, so workers do not start workers again
+func (rs *ruleSet) sequential() *ruleSet {
+	out := *rs
+	out.limits.ParallelInput = 0
+	return &out
+}
+
+// runWorkers calls fn(i) for every i in [0, n) on at most maxWorkers goroutines and waits for all of them
+func (I *Engine) runWorkers(n int, maxWorkers int, fn func(i int)) {
+	if maxWorkers > n {
+		maxWorkers = n
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(maxWorkers)
+	for w := 0; w < maxWorkers; w++ {
+		go func() {
+			defer wg.Done()
+			defer I.recoveryImpl()
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// detectParallel splits inputText into blocks at line boundaries and detects them on a worker pool,
+// results are concatenated in order of blocks, the same as detectLines on the whole input
+func (I *Engine) detectParallel(ctx context.Context, rs *ruleSet, inputText string) ([]*header.DetectResult, error) {
+	blocks := splitBlocks(inputText, rs.limits.MaxWorkers)
+	if len(blocks) <= 1 { // a single long line, detectBytes evaluates detectors in parallel
+		return I.detectLines(ctx, rs, inputText, startTextPos)
+	}
+
+	seqRs := rs.sequential()
+	starts := make([]textPos, len(blocks)) // rune offsets and lines are counted before detection
+	starts[0] = startTextPos
+	for i, end := range blocks[:len(blocks)-1] {
+		starts[i+1] = starts[i].advance(inputText[starts[i].byteOffset:end])
+	}
+	blockResults := make([][]*header.DetectResult, len(blocks))
+	blockErrs := make([]error, len(blocks))
+	I.runWorkers(len(blocks), rs.limits.MaxWorkers, func(i int) {
+		if ctx.Err() != nil {
+			blockErrs[i] = ctx.Err()
+			return
+		}
+		start := starts[i]
+		blockResults[i], blockErrs[i] = I.detectLines(ctx, seqRs, inputText[start.byteOffset:blocks[i]], start)
+	})
+
+	results := make([]*header.DetectResult, 0, DefResultSize)
+	for i := range blocks {
+		results = append(results, blockResults[i]...)
+		if blockErrs[i] != nil { // results found so far, the same as detectLines
+			return results, blockErrs[i]
+		}
+	}
+	return results, nil
+}
+
+// splitBlocks returns end positions of blocks of inputText, every block ends with '\n' except the last one
+func splitBlocks(inputText string, workers int) []int {
+	blockSize := len(inputText) / (workers * 4)
+	if blockSize < defParallelMinBlock {
+		blockSize = defParallelMinBlock
+	}
+	ends := make([]int, 0, len(inputText)/blockSize+1)
+	for start := 0; start < len(inputText); {
+		end := start + blockSize
+		if end >= len(inputText) {
+			end = len(inputText)
+		} else if idx := strings.IndexByte(inputText[end:], '\n'); idx == -1 {
+			end = len(inputText)
+		} else {
+			end += idx + 1
+		}
+		ends = append(ends, end)
+		start = end
+	}
+	return ends
+}
+
+// detectBytesParallel evaluates value detectors on a worker pool for a long line,
+// results are concatenated in order of RuleID and merged by mergeResults later
+func (I *Engine) detectBytesParallel(ctx context.Context, rs *ruleSet, line []byte) ([]*header.DetectResult, error) {
+	objs := make([]detector.API, 0, len(rs.detectorMap))
+	for _, obj := range rs.detectorMap {
+		if obj == nil || !obj.IsValue() || isMultiLine(obj) {
+			continue
+		}
+		if I.isOnlyForLog() && obj.GetRuleID() > rs.limits.MaxRegexRuleID && obj.UseRegex() {
+			continue // same as detectBytes in log processor mod
+		}
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].GetRuleID() < objs[j].GetRuleID() })
+
+	objResults := make([][]*header.DetectResult, len(objs))
+	objErrs := make([]error, len(objs))
+	I.runWorkers(len(objs), rs.limits.MaxWorkers, func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		objResults[i], objErrs[i] = objs[i].DetectBytes(line)
+	})
+
+	results := make([]*header.DetectResult, 0, DefResultSize)
+	var retErr error
+	for i := range objs {
+		results = append(results, objResults[i]...)
+		if objErrs[i] != nil {
+			retErr = objErrs[i]
+		}
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return results, ctxErr
+	}
+	// the last error will be returned
+	return results, retErr
+}
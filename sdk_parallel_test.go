@@ -0,0 +1,42 @@
+package dlp_test
+
+import (
+	"strings"
+	"testing"
+
+	dlp "github.com/laojianzi/godlp"
+)
+
+func TestEngine_Parallel(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 300; i++ {
+		sb.WriteString("我的电话是18612341234, email: abcd@abcd.com, 身份证号码：110225196403026127\n")
+	}
+	longLine := strings.Repeat("phone=18612341234 ", 300) // a single long line
+	seq := newTestEngine(t)
+	par := newTestEngine(t, dlp.WithParallel(1024, 4))
+
+	for _, inputText := range []string{sb.String(), longLine, sb.String() + longLine} {
+		wantOut, wantResults, err := seq.DeIdentify(inputText)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotOut, gotResults, err := par.DeIdentify(inputText)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotOut != wantOut {
+			t.Errorf("DeIdentify() in parallel got different output, len: %d, want len: %d", len(gotOut), len(wantOut))
+		}
+		if len(gotResults) != len(wantResults) || len(gotResults) == 0 {
+			t.Fatalf("DeIdentify() in parallel got %d results, want %d", len(gotResults), len(wantResults))
+		}
+		for i := range gotResults {
+			got, want := gotResults[i], wantResults[i]
+			if got.RuleID != want.RuleID || got.ByteStart != want.ByteStart || got.ByteEnd != want.ByteEnd ||
+				got.RuneStart != want.RuneStart || got.Line != want.Line || got.Column != want.Column {
+				t.Fatalf("DeIdentify() in parallel got results[%d] = %+v, want %+v", i, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package dlp sdk rule_set.go implements the immutable rule set snapshot of Engine
+package dlp
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/laojianzi/godlp/conf"
+	"github.com/laojianzi/godlp/detector"
+	"github.com/laojianzi/godlp/header"
+	"github.com/laojianzi/godlp/internal/keypath"
+	"github.com/laojianzi/godlp/mask"
+)
+
+// ruleSet is an immutable snapshot of the compiled config of an Engine.
+// A ruleSet is never modified after it has been stored into Engine, every change (ApplyConfig*,
+// RegisterMasker, DisableAllRules, Close) builds a new ruleSet and swaps it atomically,
+// so in-flight calls finish on the old snapshot and new calls see the new one.
+type ruleSet struct {
+	confObj     *conf.DlpConf
+	detectorMap map[int32]detector.API
+	maskerMap   map[string]mask.API
+	ruleItemMap map[int32]*conf.RuleItem // RuleID -> rule item in confObj
+	limits      Limits                   // see resolveLimits
+	// IncludePaths and ExcludePaths of Global, nil means no limit
+	includePaths keypath.Set
+	excludePaths keypath.Set
+	deobfuscate  bool   // see detectObfuscated
+	overlap      string // Overlap of Global, see mergeResults
+	// quiet are detectors of detectorMap which do not count stats, it is renewed with detectorMap
+	quiet *quietDetectors
+	// per-call options, only set in the view returned by withOptions
+	skipMask    bool
+	maskRuleMap map[string]string // InfoType -> MaskRule name
+	minScore    float64
+}
+
+// newRuleSet creates an empty ruleSet for confObj, limits of Engine override the ones in Global of confObj,
+// which override defLimits of Engine
+func newRuleSet(confObj *conf.DlpConf, defLimits Limits, limits Limits) *ruleSet {
+	rs := &ruleSet{
+		confObj:     confObj,
+		detectorMap: make(map[int32]detector.API),
+		maskerMap:   make(map[string]mask.API),
+		ruleItemMap: make(map[int32]*conf.RuleItem),
+		limits:      resolveLimits(confObj, defLimits, limits),
+		quiet:       new(quietDetectors),
+	}
+	if confObj != nil { // patterns have been checked by conf.Verify
+		rs.includePaths, _ = keypath.CompileSet(confObj.Global.IncludePaths)
+		rs.excludePaths, _ = keypath.CompileSet(confObj.Global.ExcludePaths)
+		rs.deobfuscate = confObj.Global.Deobfuscate
+		rs.overlap = confObj.Global.Overlap
+	}
+	return rs
+}
+
+// clone returns a shallow copy of rs, maps are copied so that the copy can be modified before it is stored
+func (rs *ruleSet) clone() *ruleSet {
+	out := *rs
+	out.detectorMap = make(map[int32]detector.API, len(rs.detectorMap))
+	for k, v := range rs.detectorMap {
+		out.detectorMap[k] = v
+	}
+	out.quiet = new(quietDetectors)
+	out.maskerMap = make(map[string]mask.API, len(rs.maskerMap))
+	for k, v := range rs.maskerMap {
+		out.maskerMap[k] = v
+	}
+	return &out
+}
+
+// withOptions returns a view of rs for per-call options, detectors and maskers are shared with rs
+func (rs *ruleSet) withOptions(opts []header.DetectOption) *ruleSet {
+	if len(opts) == 0 {
+		return rs
+	}
+	o := header.NewDetectOptions(opts...)
+	out := *rs
+	out.skipMask = o.SkipMask
+	out.maskRuleMap = o.MaskRules
+	out.minScore = o.MinScore
+	out.deobfuscate = rs.deobfuscate || o.Deobfuscate
+	if len(o.RuleIDs) == 0 && len(o.InfoTypes) == 0 && len(o.MinLevel) == 0 {
+		return &out
+	}
+
+	ruleIDSet := make(map[int32]struct{}, len(o.RuleIDs))
+	for _, ruleID := range o.RuleIDs {
+		ruleIDSet[ruleID] = struct{}{}
+	}
+	minLevel := levelValue(o.MinLevel)
+	out.detectorMap = make(map[int32]detector.API, len(rs.detectorMap))
+	out.quiet = new(quietDetectors)
+	for ruleID, obj := range rs.detectorMap {
+		if _, ok := ruleIDSet[ruleID]; len(ruleIDSet) > 0 && !ok {
+			continue
+		}
+		item := rs.ruleItemMap[ruleID]
+		if len(o.InfoTypes) > 0 && (item == nil || inStrList(item.InfoType, o.InfoTypes) == -1) {
+			continue
+		}
+		if minLevel > 0 && (item == nil || levelValue(item.Level) < minLevel) {
+			continue
+		}
+		out.detectorMap[ruleID] = obj
+	}
+	return &out
+}
+
+// quietDetectors are copies of detectors which do not count results removed by Filter and Verify,
+// they are built at the first use
+type quietDetectors struct {
+	once        sync.Once
+	detectorMap map[int32]detector.API
+}
+
+// quietView returns a view of rs whose detectors do not count stats, it is used by the second detection
+// of the same input, such as detectObfuscated. Detectors without WithoutCounter are used as is.
+func (rs *ruleSet) quietView() *ruleSet {
+	rs.quiet.once.Do(func() {
+		m := make(map[int32]detector.API, len(rs.detectorMap))
+		for ruleID, obj := range rs.detectorMap {
+			if q, ok := obj.(interface{ WithoutCounter() detector.API }); ok {
+				obj = q.WithoutCounter()
+			}
+			m[ruleID] = obj
+		}
+		rs.quiet.detectorMap = m
+	})
+	out := *rs
+	out.detectorMap = rs.quiet.detectorMap
+	return &out
+}
+
+// filterScore drops results whose Score is lower than the per-call MinScore
+func (rs *ruleSet) filterScore(results []*header.DetectResult) []*header.DetectResult {
+	if rs.minScore <= 0 {
+		return results
+	}
+	out := results[:0]
+	for _, res := range results {
+		if res.Score >= rs.minScore {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// filterPaths returns items of inputMap whose keys match IncludePaths and do not match ExcludePaths,
+// inputMap is returned as is if there is no path limit
+func (rs *ruleSet) filterPaths(inputMap map[string]string) map[string]string {
+	if rs.includePaths == nil && rs.excludePaths == nil {
+		return inputMap
+	}
+	out := make(map[string]string, len(inputMap))
+	for k, v := range inputMap {
+		if rs.includePaths != nil && !rs.includePaths.Match(k) {
+			continue
+		}
+		if rs.excludePaths.Match(k) {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// levelValue converts Level L1 ~ L4 into 1 ~ 4, 0 is returned for an unknown level
+func levelValue(level string) int {
+	level = strings.ToUpper(strings.TrimSpace(level))
+	if !strings.HasPrefix(level, "L") {
+		return 0
+	}
+	if v, err := strconv.Atoi(level[1:]); err == nil && v > 0 {
+		return v
+	}
+	return 0
+}
+
+// inStrList finds item in list
+func inStrList(item string, list []string) int {
+	for i, v := range list {
+		if strings.Compare(item, v) == 0 { // found
+			return i
+		}
+	}
+	return -1 // not found
+}
+
+// isDebugMode checks if the config of rs is in debug mode
+func (rs *ruleSet) isDebugMode() bool {
+	if rs.confObj == nil {
+		return false
+	}
+	return strings.Compare(strings.ToLower(rs.confObj.Global.Mode), "debug") == 0
+}
+
+// currentRuleSet returns the rule set snapshot in use, nil means the engine has not been configured
+func (I *Engine) currentRuleSet() *ruleSet {
+	if rs, ok := I.rules.Load().(*ruleSet); ok {
+		return rs
+	}
+	return nil
+}
+
+// storeRuleSet swaps the rule set snapshot in use, caller must hold I.mu
+func (I *Engine) storeRuleSet(rs *ruleSet) {
+	I.rules.Store(rs)
+}
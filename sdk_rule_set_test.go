@@ -0,0 +1,89 @@
+package dlp_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/laojianzi/godlp/header"
+)
+
+func TestEngine_DetectOptions(t *testing.T) {
+	eng := newTestEngine(t)
+
+	inputText := "我的邮件是abcd@abcd.com, 18612341234是我的电话, 我家住在北京市海淀区北三环西路43号"
+	tests := []struct {
+		name string
+		opts []header.DetectOption
+		want string
+	}{
+		{"no option", nil, "我的邮件是a***@********, 186******34是我的电话, 我家住在北京市海淀区北三环西路**号"},
+		{"rule ids", []header.DetectOption{header.WithRuleIDs(1)},
+			"我的邮件是abcd@abcd.com, 186******34是我的电话, 我家住在北京市海淀区北三环西路43号"},
+		{"info types", []header.DetectOption{header.WithInfoTypes("EMAIL", "ADDRESS")},
+			"我的邮件是a***@********, 18612341234是我的电话, 我家住在北京市海淀区北三环西路**号"},
+		{"min level", []header.DetectOption{header.WithMinLevel("L4")},
+			"我的邮件是a***@********, 186******34是我的电话, 我家住在北京市海淀区北三环西路43号"},
+		{"min score", []header.DetectOption{header.WithMinScore(0.6)},
+			"我的邮件是a***@********, 186******34是我的电话, 我家住在北京市海淀区北三环西路43号"},
+		{"without mask", []header.DetectOption{header.WithoutMask()}, inputText},
+		{"mask rule", []header.DetectOption{header.WithRuleIDs(1, 2), header.WithMaskRule("PHONE", "ExampleTAG")},
+			"我的邮件是a***@********, <PHONE>是我的电话, 我家住在北京市海淀区北三环西路43号"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := eng.DeIdentify(inputText, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("DeIdentify() \ngot = %v, \nwant = %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_DetectPaths(t *testing.T) {
+	eng := newTestEngine(t)
+	overlay := `
+Global:
+  ExcludePaths: ["/metadata/**"]
+Rules:
+  - RuleID: 10003
+    InfoType: TRACE_ID
+    Level: L2
+    Detect:
+      KDict: [trace_id]
+      Path: ["$.user..trace_id"]
+    Mask: ALL
+`
+	err := eng.ApplyConfigLayers(overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonBody := `{"user":{"trace_id":"abc","phone":"18612341234"},"trace_id":"def",` +
+		`"metadata":{"phone":"18612341234","trace_id":"xyz"}}`
+	wantJSON := `{"metadata":{"phone":"18612341234","trace_id":"xyz"},"trace_id":"def",` +
+		`"user":{"phone":"18*******34","trace_id":"***"}}`
+	out, _, err := eng.DeIdentifyJSON(jsonBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != wantJSON {
+		t.Errorf("DeIdentifyJSON() \ngot = %v, \nwant = %v", out, wantJSON)
+	}
+
+	results, err := eng.DetectMap(map[string]string{"trace_id": "abc", "/metadata/phone": "18612341234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("DetectMap() got %d results, want 0", len(results))
+	}
+
+	badOverlay := "Rules:\n  - RuleID: 10004\n    InfoType: BAD\n    Detect:\n      VDict: [bad]\n      Path: [\"/a//b\"]\n"
+	if err = eng.ApplyConfigLayers(badOverlay); !errors.Is(err, header.ErrConfVerifyFailed) {
+		t.Errorf("ApplyConfigLayers() got err = %v, want %v", err, header.ErrConfVerifyFailed)
+	}
+}
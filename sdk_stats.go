@@ -0,0 +1,292 @@
+// Package dlp sdk_stats.go implements statistics of Engine and the Prometheus text exposition of them
+package dlp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/laojianzi/godlp/header"
+)
+
+// API names of EngineStats.APIs
+const (
+	APIDetect           = "Detect"
+	APIDetectMap        = "DetectMap"
+	APIDetectJSON       = "DetectJSON"
+	APIDetectStream     = "DetectStream"
+	APIDeIdentify       = "DeIdentify"
+	APIDeIdentifyMap    = "DeIdentifyMap"
+	APIDeIdentifyJSON   = "DeIdentifyJSON"
+	APIDeIdentifyStream = "DeIdentifyStream"
+	APIMask             = "Mask"
+	APIMaskStruct       = "MaskStruct"
+	APILogProcessor     = "LogProcessor"
+)
+
+// defLatencyBounds are the default upper bounds of latency histogram buckets in seconds, see WithLatencyBounds
+var defLatencyBounds = []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Stats returns a snapshot of hit counts of rules, call counts, bytes scanned and latency of APIs
+// 返回规则命中次数、接口调用次数、扫描字节数和耗时分布的统计快照
+func (I *Engine) Stats() *header.EngineStats {
+	return I.stats.snapshot()
+}
+
+// NewStatsHandler returns a http.Handler which renders Stats() of eng in Prometheus text exposition format
+// 返回 http.Handler，以 Prometheus 文本格式输出 eng 的统计数据
+func NewStatsHandler(eng header.EngineAPI) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteStatsPrometheus(w, eng.Stats())
+	})
+}
+
+// WriteStatsPrometheus writes stats into w in Prometheus text exposition format
+func WriteStatsPrometheus(w io.Writer, stats *header.EngineStats) error {
+	bw := bufio.NewWriter(w)
+	ruleIDs := make([]int32, 0, len(stats.Rules))
+	for ruleID := range stats.Rules {
+		ruleIDs = append(ruleIDs, ruleID)
+	}
+	sort.Slice(ruleIDs, func(i, j int) bool { return ruleIDs[i] < ruleIDs[j] })
+	apis := make([]string, 0, len(stats.APIs))
+	for api := range stats.APIs {
+		apis = append(apis, api)
+	}
+	sort.Strings(apis)
+
+	ruleCounters := []struct {
+		name, help string
+		value      func(s header.RuleStats) int64
+	}{
+		{"godlp_rule_hits_total", "Results of the rule returned to caller.",
+			func(s header.RuleStats) int64 { return s.Hits }},
+		{"godlp_rule_filtered_total", "Results of the rule removed by Filter.",
+			func(s header.RuleStats) int64 { return s.Filtered }},
+		{"godlp_rule_verified_out_total", "Results of the rule removed by Verify.",
+			func(s header.RuleStats) int64 { return s.VerifiedOut }},
+	}
+	for _, c := range ruleCounters {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, ruleID := range ruleIDs {
+			fmt.Fprintf(bw, "%s{rule_id=\"%d\"} %d\n", c.name, ruleID, c.value(stats.Rules[ruleID]))
+		}
+	}
+
+	apiCounters := []struct {
+		name, help string
+		value      func(s header.APIStats) int64
+	}{
+		{"godlp_api_calls_total", "Calls of the API.",
+			func(s header.APIStats) int64 { return s.Calls }},
+		{"godlp_api_errors_total", "Calls of the API which return an error.",
+			func(s header.APIStats) int64 { return s.Errors }},
+		{"godlp_api_bytes_scanned_total", "Bytes of input of the API.",
+			func(s header.APIStats) int64 { return s.BytesScanned }},
+	}
+	for _, c := range apiCounters {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, api := range apis {
+			fmt.Fprintf(bw, "%s{api=%q} %d\n", c.name, api, c.value(stats.APIs[api]))
+		}
+	}
+
+	name := "godlp_api_latency_seconds"
+	fmt.Fprintf(bw, "# HELP %s Latency of the API.\n# TYPE %s histogram\n", name, name)
+	for _, api := range apis {
+		h := stats.APIs[api].Latency
+		for i, bound := range h.Bounds {
+			fmt.Fprintf(bw, "%s_bucket{api=%q,le=%q} %d\n", name, api,
+				strconv.FormatFloat(bound, 'g', -1, 64), h.Counts[i])
+		}
+		fmt.Fprintf(bw, "%s_bucket{api=%q,le=\"+Inf\"} %d\n", name, api, h.Count)
+		fmt.Fprintf(bw, "%s_sum{api=%q} %s\n", name, api, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count{api=%q} %d\n", name, api, h.Count)
+	}
+
+	fmt.Fprintf(bw, "# HELP godlp_bytes_scanned_total Bytes of input of all APIs.\n"+
+		"# TYPE godlp_bytes_scanned_total counter\ngodlp_bytes_scanned_total %d\n", stats.BytesScanned)
+	return bw.Flush()
+}
+
+// private func
+
+// engineStats collects statistics of an Engine, it is safe for concurrent use
+// and implements detector.Counter
+type engineStats struct {
+	bytesScanned int64
+	bounds       []float64              // upper bounds of latency buckets, never modified after newEngineStats
+	apis         map[string]*apiCounter // fixed after newEngineStats, no lock is needed
+	mu           sync.RWMutex           // guards rules
+	rules        map[int32]*ruleCounter
+}
+
+// ruleCounter is the counters of a rule
+type ruleCounter struct {
+	hits        int64
+	filtered    int64
+	verifiedOut int64
+}
+
+// apiCounter is the counters of an API
+type apiCounter struct {
+	calls        int64
+	errors       int64
+	bytesScanned int64
+	latencySum   int64   // nanoseconds
+	buckets      []int64 // buckets[i] is the number of observations in (bounds[i-1], bounds[i]]
+}
+
+// newEngineStats creates engineStats with counters of all APIs, bounds are copied and sorted,
+// defLatencyBounds are used if bounds is empty
+func newEngineStats(bounds []float64) *engineStats {
+	if len(bounds) == 0 {
+		bounds = defLatencyBounds
+	}
+	s := &engineStats{
+		bounds: append([]float64(nil), bounds...),
+		apis:   make(map[string]*apiCounter),
+		rules:  make(map[int32]*ruleCounter),
+	}
+	sort.Float64s(s.bounds)
+	for _, api := range []string{
+		APIDetect, APIDetectMap, APIDetectJSON, APIDetectStream,
+		APIDeIdentify, APIDeIdentifyMap, APIDeIdentifyJSON, APIDeIdentifyStream,
+		APIMask, APIMaskStruct, APILogProcessor,
+	} {
+		s.apis[api] = &apiCounter{buckets: make([]int64, len(s.bounds)+1)}
+	}
+	return s
+}
+
+// observe records a call of api, results and err are read when observe is called,
+// so it can be deferred with pointers of named return values
+func (s *engineStats) observe(api string, start time.Time, size int,
+	results *[]*header.DetectResult, err *error,
+) {
+	if s == nil {
+		return
+	}
+	c := s.apis[api]
+	atomic.AddInt64(&c.calls, 1)
+	if err != nil && *err != nil {
+		atomic.AddInt64(&c.errors, 1)
+	}
+	atomic.AddInt64(&c.bytesScanned, int64(size))
+	atomic.AddInt64(&s.bytesScanned, int64(size))
+	elapsed := time.Since(start)
+	atomic.AddInt64(&c.latencySum, int64(elapsed))
+	seconds := elapsed.Seconds()
+	idx := sort.SearchFloat64s(s.bounds, seconds) // first bound >= seconds
+	atomic.AddInt64(&c.buckets[idx], 1)
+	if results != nil {
+		s.addHits(*results)
+	}
+}
+
+// addHits records results returned to caller
+func (s *engineStats) addHits(results []*header.DetectResult) {
+	if s == nil {
+		return
+	}
+	for _, res := range results {
+		atomic.AddInt64(&s.rule(res.RuleID).hits, 1)
+	}
+}
+
+// AddFiltered implements detector.Counter
+func (s *engineStats) AddFiltered(ruleID int32, n int) {
+	atomic.AddInt64(&s.rule(ruleID).filtered, int64(n))
+}
+
+// AddVerifiedOut implements detector.Counter
+func (s *engineStats) AddVerifiedOut(ruleID int32, n int) {
+	atomic.AddInt64(&s.rule(ruleID).verifiedOut, int64(n))
+}
+
+// rule returns counters of ruleID, they are created at the first time
+func (s *engineStats) rule(ruleID int32) *ruleCounter {
+	s.mu.RLock()
+	c, ok := s.rules[ruleID]
+	s.mu.RUnlock()
+	if ok {
+		return c
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok = s.rules[ruleID]; !ok {
+		c = new(ruleCounter)
+		s.rules[ruleID] = c
+	}
+	return c
+}
+
+// snapshot returns the statistics at the moment
+func (s *engineStats) snapshot() *header.EngineStats {
+	out := &header.EngineStats{
+		Rules: make(map[int32]header.RuleStats),
+		APIs:  make(map[string]header.APIStats),
+	}
+	if s == nil {
+		return out
+	}
+	out.BytesScanned = atomic.LoadInt64(&s.bytesScanned)
+	s.mu.RLock()
+	for ruleID, c := range s.rules {
+		out.Rules[ruleID] = header.RuleStats{
+			Hits:        atomic.LoadInt64(&c.hits),
+			Filtered:    atomic.LoadInt64(&c.filtered),
+			VerifiedOut: atomic.LoadInt64(&c.verifiedOut),
+		}
+	}
+	s.mu.RUnlock()
+	for api, c := range s.apis {
+		h := header.Histogram{
+			Bounds: append([]float64(nil), s.bounds...),
+			Counts: make([]int64, len(s.bounds)),
+			Sum:    time.Duration(atomic.LoadInt64(&c.latencySum)).Seconds(),
+		}
+		for i := range c.buckets {
+			h.Count += atomic.LoadInt64(&c.buckets[i])
+			if i < len(h.Counts) {
+				h.Counts[i] = h.Count // cumulative
+			}
+		}
+		out.APIs[api] = header.APIStats{
+			Calls:        atomic.LoadInt64(&c.calls),
+			Errors:       atomic.LoadInt64(&c.errors),
+			BytesScanned: atomic.LoadInt64(&c.bytesScanned),
+			Latency:      h,
+		}
+	}
+	return out
+}
+
+// mapSize returns the bytes of keys and values of inputMap
+func mapSize(inputMap map[string]string) int {
+	size := 0
+	for k, v := range inputMap {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+// countReader counts bytes read from Reader
+type countReader struct {
+	io.Reader
+	n int
+}
+
+// Read implements io.Reader
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += n
+	return n, err
+}
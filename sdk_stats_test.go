@@ -0,0 +1,119 @@
+package dlp_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dlp "github.com/laojianzi/godlp"
+	"github.com/laojianzi/godlp/header"
+)
+
+func TestEngine_Stats(t *testing.T) {
+	eng := newTestEngine(t)
+
+	inputText := "18612341234是我的电话"
+	for i := 0; i < 3; i++ {
+		if _, err := eng.Detect(inputText); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, err := eng.DeIdentifyMap(map[string]string{"phone": "18612341234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// email masked already is removed by BAlgo MASKED
+	if _, err = eng.Detect("a***@abcd.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = eng.Mask(inputText, "NOT_EXIST"); err == nil {
+		t.Fatal("Mask() got nil error")
+	}
+
+	stats := eng.Stats()
+	if got := stats.APIs[dlp.APIDetect]; got.Calls != 4 || got.Errors != 0 || got.Latency.Count != 4 ||
+		got.BytesScanned != int64(3*len(inputText)+len("a***@abcd.com")) {
+		t.Errorf("Stats() Detect got = %+v", got)
+	}
+	if got := stats.APIs[dlp.APIMask]; got.Calls != 1 || got.Errors != 1 {
+		t.Errorf("Stats() Mask got = %+v", got)
+	}
+	if got := stats.APIs[dlp.APIDeIdentifyMap]; got.Calls != 1 {
+		t.Errorf("Stats() DeIdentifyMap got = %+v", got)
+	}
+	results, _ := eng.Detect(inputText)
+	phoneRuleID := results[0].RuleID
+	if got := stats.Rules[phoneRuleID]; got.Hits != 3 {
+		t.Errorf("Stats() RuleID:%d got = %+v, want 3 hits", phoneRuleID, got)
+	}
+	filtered := int64(0)
+	for _, s := range stats.Rules {
+		filtered += s.Filtered
+	}
+	if filtered == 0 {
+		t.Errorf("Stats() got no filtered result")
+	}
+
+	rec := httptest.NewRecorder()
+	dlp.NewStatsHandler(eng).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`godlp_api_calls_total{api="Detect"} 5`,
+		`godlp_api_latency_seconds_count{api="Detect"} 5`,
+		`godlp_api_latency_seconds_bucket{api="Detect",le="+Inf"} 5`,
+		"# TYPE godlp_rule_hits_total counter",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("NewStatsHandler() body does not contain %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestEngine_StatsDeobfuscation(t *testing.T) {
+	// the masked email is removed by BAlgo MASKED, the spaced phone is only found by de-obfuscation
+	inputText := "a***@abcd.com call 1 8 6-1234-1234"
+	stats := make([]*header.EngineStats, 0, 2)
+	for _, opts := range [][]header.DetectOption{nil, {header.WithDeobfuscation()}} {
+		eng := newTestEngine(t)
+		if _, err := eng.Detect(inputText, opts...); err != nil {
+			t.Fatal(err)
+		}
+		stats = append(stats, eng.Stats())
+	}
+
+	filtered := int64(0)
+	for ruleID, want := range stats[0].Rules {
+		got := stats[1].Rules[ruleID]
+		if got.Filtered != want.Filtered || got.VerifiedOut != want.VerifiedOut {
+			t.Errorf("Stats() with de-obfuscation RuleID:%d got = %+v, want = %+v", ruleID, got, want)
+		}
+		filtered += want.Filtered
+	}
+	if filtered == 0 {
+		t.Errorf("Stats() got no filtered result")
+	}
+}
+
+func TestEngine_StatsOptions(t *testing.T) {
+	bounds := []float64{1, 0.5}
+	eng := newTestEngine(t, dlp.WithLatencyBounds(bounds...), dlp.WithMaxRegexRuleID(1099))
+	bounds[0] = 2 // bounds are copied
+
+	// results of log processor are counted as hits
+	inputText := "18612341234是我的电话"
+	if out, _, _ := eng.NewLogProcessor()(inputText, "email", "abcd@abcd.com"); out == inputText {
+		t.Fatalf("NewLogProcessor() got = %q, want masked", out)
+	}
+	stats := eng.Stats()
+	got := stats.APIs[dlp.APILogProcessor]
+	if got.Calls != 1 || len(got.Latency.Bounds) != 2 || got.Latency.Bounds[0] != 0.5 || got.Latency.Bounds[1] != 1 {
+		t.Errorf("Stats() LogProcessor got = %+v, want 1 call with bounds [0.5 1]", got)
+	}
+	hits := int64(0)
+	for _, s := range stats.Rules {
+		hits += s.Hits
+	}
+	if hits != 2 {
+		t.Errorf("Stats() got %d hits of LogProcessor, want 2", hits)
+	}
+}
@@ -1,8 +1,10 @@
 package dlp_test
 
 import (
+	"errors"
 	"os"
 	"runtime"
+	"sync"
 	"testing"
 
 	"gopkg.in/yaml.v2"
@@ -148,6 +150,65 @@ func TestDeIdentifyJSONByResult(t *testing.T) {
 	}
 }
 
+func TestEngine_Concurrent(t *testing.T) {
+	eng := newTestEngine(t)
+
+	inputText := "我的邮件是abcd@abcd.com, 18612341234是我的电话"
+	wantOutputText := "我的邮件是a***@********, 186******34是我的电话"
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := eng.Detect(inputText); err != nil {
+					if errors.Is(err, header.ErrProcessAfterClose) {
+						return
+					}
+					t.Error(err)
+					return
+				}
+				out, _, err := eng.DeIdentify(inputText)
+				if err != nil {
+					if errors.Is(err, header.ErrProcessAfterClose) {
+						return
+					}
+					t.Error(err)
+					return
+				}
+				if out != wantOutputText {
+					t.Errorf("DeIdentify() got = %v, want = %v", out, wantOutputText)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 5; j++ {
+			if err := eng.ApplyConfig(eng.GetDefaultConf()); err != nil {
+				t.Error(err)
+				return
+			}
+			runtime.Gosched()
+		}
+		if err := eng.RegisterMasker("ConcurrentMasker", func(in string) (string, error) {
+			return in, nil
+		}); err != nil {
+			t.Error(err)
+		}
+		eng.Close()
+	}()
+	wg.Wait()
+
+	_, err := eng.Detect(inputText)
+	if !errors.Is(err, header.ErrProcessAfterClose) {
+		t.Errorf("Detect() after Close() got err = %v, want %v", err, header.ErrProcessAfterClose)
+	}
+}
+
 // private func
 
 func setup() {
@@ -156,3 +217,17 @@ func setup() {
 }
 
 func shutdown() {}
+
+// newTestEngine creates an engine with opts and the default config, the engine is closed when t finishes
+func newTestEngine(t *testing.T, opts ...dlp.EngineOption) header.EngineAPI {
+	t.Helper()
+	eng, err := dlp.NewEngineWithOptions("replace.your.psm", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(eng.Close)
+	if err = eng.ApplyConfigDefault(); err != nil {
+		t.Fatal(err)
+	}
+	return eng
+}